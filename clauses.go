@@ -1,7 +1,11 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
 )
 
 const (
@@ -10,50 +14,641 @@ const (
 	sqlComma = ", "
 )
 
+// Comparison Operators
+const (
+	OpEqual        = "="
+	OpNotEqual     = "<>"
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpLike         = "LIKE"
+	OpILike        = "ILIKE"
+)
+
+// Operators understood by NamedComparison, with the suffix given to their
+// parameter names.
+var operatorSuffixes = map[string]string{
+	OpEqual:        "",
+	OpNotEqual:     "_ne",
+	OpGreater:      "_gt",
+	OpGreaterEqual: "_gte",
+	OpLess:         "_lt",
+	OpLessEqual:    "_lte",
+	OpLike:         "_like",
+	OpILike:        "_ilike",
+}
+
+// CompileContext is shared by every clause of a single statement while it is
+// compiled, so that parameter names never collide across clauses.
+type CompileContext struct {
+	// Driver is the sqlx driver name of the target database, if known.
+	Driver string
+	names  map[string]bool
+	err    error
+}
+
+func NewCompileContext() *CompileContext {
+	return &CompileContext{
+		names: make(map[string]bool),
+	}
+}
+
+// newCompileContext targets the dialect of db when it reports one.
+func newCompileContext(db Executor) *CompileContext {
+	ctx := NewCompileContext()
+	if d, ok := db.(interface {
+		DriverName() string
+	}); ok {
+		ctx.Driver = d.DriverName()
+	}
+	return ctx
+}
+
+// Fail records that the statement can't be compiled for this context, such
+// as when it uses a feature the driver doesn't support. The first failure is
+// returned by Err and stops the statement from being executed.
+func (c *CompileContext) Fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *CompileContext) Err() error {
+	return c.err
+}
+
+// compileFor compiles c for the dialect of db.
+func compileFor(db Executor, c Clause) (string, map[string]interface{}, error) {
+	ctx := newCompileContext(db)
+	stmt, obj := c.Compile(ctx)
+	return stmt, obj, ctx.Err()
+}
+
+// execute compiles c for db and executes it.
+func execute(db Executor, c Clause) (sql.Result, error) {
+	stmt, obj, err := compileFor(db, c)
+	if err != nil {
+		return nil, err
+	}
+	return db.NamedExec(stmt, obj)
+}
+
+// Name reserves a parameter name for this statement, numbering base if it has
+// already been used (variable_id, variable_id_1, variable_id_2, ...).
+// Characters other than letters, digits and underscores become underscores.
+func (c *CompileContext) Name(base string) string {
+	base = strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, base)
+
+	name := base
+	for i := 1; c.names[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	c.names[name] = true
+	return name
+}
+
 type Clause interface {
-	Compile() (string, map[string]interface{})
+	Compile(ctx *CompileContext) (string, map[string]interface{})
 }
 
 type LimitClause struct {
 	Number int
 }
 
-func (c LimitClause) Compile() (string, map[string]interface{}) {
+func (c LimitClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
 	return fmt.Sprintf("%d", c.Number), nil
 }
 
+type OffsetClause struct {
+	Number int
+}
+
+func (c OffsetClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return fmt.Sprintf("%d", c.Number), nil
+}
+
+// DISTINCT, or DISTINCT ON when On is given, which SQLite rejects
+type DistinctClause struct {
+	On []Clause
+}
+
+func (c DistinctClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	if len(c.On) == 0 {
+		return "DISTINCT", nil
+	}
+
+	if ctx.Driver == "sqlite3" {
+		ctx.Fail(fmt.Errorf("DISTINCT ON is not supported by %s.", ctx.Driver))
+	}
+	onStmt, onObj := JoinClausesOn(ctx, c.On, sqlComma)
+	return fmt.Sprintf("DISTINCT ON (%s)", onStmt), onObj
+}
+
+// Row Lock Strengths
+const (
+	LockUpdate = "UPDATE"
+	LockShare  = "SHARE"
+)
+
+// Row Lock Waiting
+const (
+	LockWait       = ""
+	LockNoWait     = "NOWAIT"
+	LockSkipLocked = "SKIP LOCKED"
+)
+
+// Row Locking, which SQLite has no need for and rejects
+type LockClause struct {
+	Strength string
+	Wait     string
+}
+
+func (c LockClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	if ctx.Driver == "sqlite3" {
+		ctx.Fail(fmt.Errorf("FOR %s is not supported by %s.", c.Strength, ctx.Driver))
+	}
+	if c.Wait == LockWait {
+		return fmt.Sprintf("FOR %s", c.Strength), nil
+	}
+	return fmt.Sprintf("FOR %s %s", c.Strength, c.Wait), nil
+}
+
+// Join Types
+const (
+	InnerJoin = "INNER"
+	LeftJoin  = "LEFT"
+	RightJoin = "RIGHT"
+	CrossJoin = "CROSS"
+)
+
+type JoinClause struct {
+	Type  string
+	Table string
+	Alias string
+	On    Clause
+}
+
+func (c JoinClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	table := c.Table
+	if c.Alias != "" {
+		table = fmt.Sprintf("%s AS %s", c.Table, c.Alias)
+	}
+
+	if c.On == nil {
+		return fmt.Sprintf("%s JOIN %s", c.Type, table), nil
+	}
+
+	onStmt, onObj := c.On.Compile(ctx)
+	return fmt.Sprintf("%s JOIN %s ON (%s)", c.Type, table, onStmt), onObj
+}
+
+// Placement of NULLs in an ordering
+type NullsOrder int
+
+const (
+	NullsDefault NullsOrder = iota
+	NullsFirst
+	NullsLast
+)
+
 type OrderClause struct {
 	Key       string
 	Ascending bool
+	Nulls     NullsOrder
 }
 
-func (c OrderClause) Compile() (string, map[string]interface{}) {
+func (c OrderClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
 	orderType := "ASC"
 	if !c.Ascending {
 		orderType = "DESC"
 	}
-	return fmt.Sprintf("%s %s", c.Key, orderType), nil
+	order := fmt.Sprintf("%s %s", c.Key, orderType)
+
+	if c.Nulls == NullsDefault {
+		return order, nil
+	}
+
+	if c.Nulls == NullsFirst {
+		return order + " NULLS FIRST", nil
+	}
+	return order + " NULLS LAST", nil
 }
 
 // SQL And Clauses
 type AndClauses []Clause
 
-func (c AndClauses) Compile() (string, map[string]interface{}) {
-	return JoinClausesOn(c, sqlAnd)
+func (c AndClauses) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return JoinClausesOn(ctx, parenthesize(c), sqlAnd)
 }
 
 // SQL Or Clauses
 type OrClauses []Clause
 
-func (c OrClauses) Compile() (string, map[string]interface{}) {
-	return JoinClausesOn(c, sqlOr)
+func (c OrClauses) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return JoinClausesOn(ctx, parenthesize(c), sqlOr)
+}
+
+// SQL Not Clause
+type NotClause struct {
+	Clause Clause
+}
+
+func Not(c Clause) Clause {
+	return &NotClause{
+		Clause: c,
+	}
+}
+
+func (c *NotClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	stmt, obj := c.Clause.Compile(ctx)
+	return fmt.Sprintf("NOT (%s)", stmt), obj
+}
+
+// Parenthesized Clause
+type parenClause struct {
+	Clause
+}
+
+func (c parenClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	stmt, obj := c.Clause.Compile(ctx)
+	return fmt.Sprintf("(%s)", stmt), obj
+}
+
+// Wraps nested AND/OR groups so they keep their meaning when joined.
+func parenthesize(c []Clause) []Clause {
+	out := make([]Clause, len(c))
+	for i, v := range c {
+		out[i] = v
+		if isCompound(v) {
+			out[i] = parenClause{v}
+		}
+	}
+	return out
+}
+
+func isCompound(c Clause) bool {
+	switch v := c.(type) {
+	case AndClauses:
+		return len(v) > 1
+	case OrClauses:
+		return len(v) > 1
+	case *Group:
+		return isCompound(v.Clause)
+	case *RawClause:
+		// Raw SQL may hold operators of any precedence.
+		return true
+	}
+	return false
+}
+
+// andClause joins where onto existing, always returning a fresh slice.
+func andClause(existing Clause, where Clause) Clause {
+	switch v := existing.(type) {
+	case nil:
+		return AndClauses{where}
+	case AndClauses:
+		out := make(AndClauses, len(v), len(v)+1)
+		copy(out, v)
+		return append(out, where)
+	}
+	return AndClauses{existing, where}
+}
+
+// orClause joins where onto existing, always returning a fresh slice.
+func orClause(existing Clause, where Clause) Clause {
+	switch v := existing.(type) {
+	case nil:
+		return OrClauses{where}
+	case OrClauses:
+		out := make(OrClauses, len(v), len(v)+1)
+		copy(out, v)
+		return append(out, where)
+	}
+	return OrClauses{existing, where}
+}
+
+// A Group collects where clauses that are compiled together, in parentheses
+// when nested inside another AND or OR.
+type Group struct {
+	Clause Clause
+}
+
+func (g *Group) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	if g.Clause == nil {
+		return "1 = 1", nil
+	}
+	return g.Clause.Compile(ctx)
+}
+
+func (g *Group) And(where Clause) *Group {
+	g.Clause = andClause(g.Clause, where)
+	return g
+}
+
+func (g *Group) Or(where Clause) *Group {
+	g.Clause = orClause(g.Clause, where)
+	return g
+}
+
+func (g *Group) Where(key string, value interface{}) *Group {
+	return g.And(&NamedEquality{
+		Name:  key,
+		Value: value,
+	})
+}
+
+func (g *Group) OrWhere(key string, value interface{}) *Group {
+	return g.Or(&NamedEquality{
+		Name:  key,
+		Value: value,
+	})
+}
+
+func (g *Group) Not(where Clause) *Group {
+	return g.And(Not(where))
 }
 
 // SQL Set Clause
 type SetClause []Clause
 
-func (c SetClause) Compile() (string, map[string]interface{}) {
-	return JoinClausesOn(c, sqlComma)
+func (c SetClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return JoinClausesOn(ctx, c, sqlComma)
+}
+
+// Projected Column, optionally qualified by its table
+type Column struct {
+	Table string
+	Name  string
+}
+
+func (c Column) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	name := quoteIdentifier(c.Name)
+	if c.Name == "*" {
+		name = "*"
+	}
+	if c.Table != "" {
+		name = fmt.Sprintf("%s.%s", quoteIdentifier(c.Table), name)
+	}
+	return name, nil
+}
+
+// ParseColumn splits an optionally qualified "table.column" name.
+func ParseColumn(name string) Column {
+	if i := strings.LastIndex(name, "."); i != -1 {
+		return Column{
+			Table: name[:i],
+			Name:  name[i+1:],
+		}
+	}
+	return Column{
+		Name: name,
+	}
+}
+
+// Projected SQL Expression, copied verbatim into the statement
+type Expression string
+
+func (c Expression) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return string(c), nil
+}
+
+// Aliased Clause
+type AliasClause struct {
+	Clause Clause
+	Alias  string
+}
+
+func As(c Clause, alias string) Clause {
+	return &AliasClause{
+		Clause: c,
+		Alias:  alias,
+	}
+}
+
+func (c *AliasClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	stmt, obj := c.Clause.Compile(ctx)
+	return fmt.Sprintf("%s AS %s", stmt, quoteIdentifier(c.Alias)), obj
+}
+
+// columnName quotes an optionally qualified "table.column" name.
+func columnName(name string) string {
+	stmt, _ := ParseColumn(name).Compile(nil)
+	return stmt
+}
+
+// Column to Column Equality, as used to join tables
+type ColumnEquality struct {
+	Left  string
+	Right string
+}
+
+func On(left string, right string) Clause {
+	return &ColumnEquality{
+		Left:  left,
+		Right: right,
+	}
+}
+
+func (c *ColumnEquality) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return fmt.Sprintf("%s = %s", columnName(c.Left), columnName(c.Right)), nil
+}
+
+// Nested projects columns of table aliased as "prefix.column", which sqlx
+// scans into the fields of a nested struct named prefix.
+func Nested(table string, prefix string, columns ...string) []Clause {
+	out := make([]Clause, len(columns))
+	for i, v := range columns {
+		out[i] = As(Column{
+			Table: table,
+			Name:  v,
+		}, prefix+"."+v)
+	}
+	return out
+}
+
+// Aggregate Function over a column, or * for COUNT(*)
+type Aggregate struct {
+	Function string
+	Column   string
+	Distinct bool
+}
+
+func Count(column string) Aggregate {
+	return Aggregate{Function: "COUNT", Column: column}
+}
+
+func CountDistinct(column string) Aggregate {
+	return Aggregate{Function: "COUNT", Column: column, Distinct: true}
+}
+
+func Sum(column string) Aggregate {
+	return Aggregate{Function: "SUM", Column: column}
+}
+
+func Avg(column string) Aggregate {
+	return Aggregate{Function: "AVG", Column: column}
+}
+
+func Min(column string) Aggregate {
+	return Aggregate{Function: "MIN", Column: column}
+}
+
+func Max(column string) Aggregate {
+	return Aggregate{Function: "MAX", Column: column}
+}
+
+func (c Aggregate) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	column := "*"
+	if c.Column != "*" {
+		column = columnName(c.Column)
+	}
+	if c.Distinct {
+		column = "DISTINCT " + column
+	}
+	return fmt.Sprintf("%s(%s)", c.Function, column), nil
+}
+
+func (c Aggregate) As(alias string) Clause {
+	return As(c, alias)
+}
+
+// Window Function, evaluated over a partition of the result rows
+type Window struct {
+	Function   Clause
+	Partitions []Clause
+	Ordering   []OrderClause
+}
+
+// Over evaluates function, such as an Aggregate, as a window function.
+func Over(function Clause) *Window {
+	return &Window{
+		Function: function,
+	}
+}
+
+func RowNumber() *Window {
+	return Over(Expression("ROW_NUMBER()"))
+}
+
+func Rank() *Window {
+	return Over(Expression("RANK()"))
+}
+
+func DenseRank() *Window {
+	return Over(Expression("DENSE_RANK()"))
+}
+
+func (w *Window) clone() *Window {
+	out := *w
+	out.Partitions = append([]Clause(nil), w.Partitions...)
+	out.Ordering = append([]OrderClause(nil), w.Ordering...)
+	return &out
+}
+
+func (w *Window) PartitionBy(columns ...string) *Window {
+	w = w.clone()
+	for _, v := range columns {
+		w.Partitions = append(w.Partitions, ParseColumn(v))
+	}
+	return w
+}
+
+func (w *Window) OrderBy(key string, ascending bool) *Window {
+	w = w.clone()
+	w.Ordering = append(w.Ordering, OrderClause{
+		Key:       key,
+		Ascending: ascending,
+	})
+	return w
+}
+
+func (w *Window) As(alias string) Clause {
+	return As(w, alias)
+}
+
+func (w *Window) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	outStmt, outObj := w.Function.Compile(ctx)
+
+	window := make([]string, 0, 2)
+	if len(w.Partitions) > 0 {
+		partitionStmt, partitionObj := JoinClausesOn(ctx, w.Partitions, sqlComma)
+		window = append(window, "PARTITION BY "+partitionStmt)
+		outObj = mapUnion(outObj, partitionObj)
+	}
+	if len(w.Ordering) > 0 {
+		orders := make([]Clause, len(w.Ordering))
+		for i, v := range w.Ordering {
+			orders[i] = v
+		}
+		orderStmt, orderObj := JoinClausesOn(ctx, orders, sqlComma)
+		window = append(window, "ORDER BY "+orderStmt)
+		outObj = mapUnion(outObj, orderObj)
+	}
+
+	return fmt.Sprintf("%s OVER (%s)", outStmt, strings.Join(window, " ")), outObj
+}
+
+// Comparison of an expression, such as an Aggregate, against a value
+type Comparison struct {
+	Left     Clause
+	Operator string
+	Value    interface{}
+}
+
+func Compare(left Clause, operator string, value interface{}) Clause {
+	return &Comparison{
+		Left:     left,
+		Operator: operator,
+		Value:    value,
+	}
+}
+
+func (c *Comparison) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	operator, _ := checkOperator(ctx, c.Operator)
+	leftStmt, leftObj := c.Left.Compile(ctx)
+	valueStmt, valueObj := bindValue(ctx, "variable_value", c.Value)
+	return fmt.Sprintf("%s %s %s", leftStmt, operator, valueStmt), mapUnion(leftObj, valueObj)
+}
+
+// checkOperator resolves operator, accepting != for <>, and returns it with
+// the suffix of its parameter names. Unknown operators, and ILIKE on SQLite,
+// fail the statement.
+func checkOperator(ctx *CompileContext, operator string) (string, string) {
+	if operator == "!=" {
+		operator = OpNotEqual
+	}
+
+	suffix, ok := operatorSuffixes[operator]
+	if !ok {
+		ctx.Fail(fmt.Errorf("Unknown comparison operator %s.", operator))
+	} else if operator == OpILike && ctx.Driver == "sqlite3" {
+		ctx.Fail(fmt.Errorf("%s is not supported by %s.", operator, ctx.Driver))
+	}
+	return operator, suffix
+}
+
+// bindValue binds value to a parameter named after base. A Clause value, such
+// as a *SelectStatement, is compiled in place as a parenthesized subquery.
+func bindValue(ctx *CompileContext, base string, value interface{}) (string, map[string]interface{}) {
+	if clause, ok := value.(Clause); ok {
+		stmt, obj := clause.Compile(ctx)
+		return fmt.Sprintf("(%s)", stmt), obj
+	}
+
+	name := ctx.Name(base)
+	return ":" + name, map[string]interface{}{
+		name: value,
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
 }
 
 // Basic Variable Equality
@@ -62,9 +657,248 @@ type NamedEquality struct {
 	Value interface{}
 }
 
-func (c *NamedEquality) Compile() (string, map[string]interface{}) {
+func (c *NamedEquality) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	value, object := bindValue(ctx, fmt.Sprintf("variable_%s", c.Name), c.Value)
+	return fmt.Sprintf("%s = %s", columnName(c.Name), value), object
+}
+
+// Basic Variable Comparison
+type NamedComparison struct {
+	Name     string
+	Operator string
+	Value    interface{}
+}
+
+func (c *NamedComparison) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	operator, suffix := checkOperator(ctx, c.Operator)
+	value, object := bindValue(ctx, fmt.Sprintf("variable_%s%s", c.Name, suffix), c.Value)
+	return fmt.Sprintf("%s %s %s", columnName(c.Name), operator, value), object
+}
+
+// Variable Range (inclusive)
+type NamedBetween struct {
+	Name string
+	Low  interface{}
+	High interface{}
+}
+
+func (c *NamedBetween) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	low, lowObj := bindValue(ctx, fmt.Sprintf("variable_%s_low", c.Name), c.Low)
+	high, highObj := bindValue(ctx, fmt.Sprintf("variable_%s_high", c.Name), c.High)
+	return fmt.Sprintf("%s BETWEEN %s AND %s", columnName(c.Name), low, high), mapUnion(lowObj, highObj)
+}
+
+// Variable Null Check
+type NamedNull struct {
+	Name string
+	Not  bool
+}
+
+func (c *NamedNull) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	if c.Not {
+		return fmt.Sprintf("%s IS NOT NULL", columnName(c.Name)), nil
+	}
+	return fmt.Sprintf("%s IS NULL", columnName(c.Name)), nil
+}
+
+// Variable Membership, expanding a slice into one parameter per element, or
+// testing against a subquery when Values is a Clause
+type In struct {
+	Name   string
+	Values interface{}
+}
+
+func (c *In) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return compileMembership(ctx, c.Name, c.Values, false)
+}
+
+// Variable Non-Membership
+type NotIn struct {
+	Name   string
+	Values interface{}
+}
+
+func (c *NotIn) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return compileMembership(ctx, c.Name, c.Values, true)
+}
+
+func compileMembership(ctx *CompileContext, column string, values interface{}, not bool) (string, map[string]interface{}) {
+	operator := "IN"
+	suffix := "in"
+	if not {
+		operator = "NOT IN"
+		suffix = "not_in"
+	}
+
+	if query, ok := values.(Clause); ok {
+		stmt, obj := query.Compile(ctx)
+		return fmt.Sprintf("%s %s (%s)", columnName(column), operator, stmt), obj
+	}
+
+	// Byte slices are single values rather than lists.
+	list := reflect.ValueOf([]interface{}{})
+	if values != nil {
+		list = reflect.ValueOf(values)
+		if _, ok := values.([]byte); ok || list.Kind() != reflect.Slice {
+			list = reflect.ValueOf([]interface{}{values})
+		}
+	}
+
+	// Nothing is in the empty set.
+	if list.Len() == 0 {
+		if not {
+			return "1 = 1", nil
+		}
+		return "1 = 0", nil
+	}
+
 	object := make(map[string]interface{})
-	name := fmt.Sprintf("variable_%s", c.Name)
-	object[name] = c.Value
-	return fmt.Sprintf("\"%s\" = :%s", c.Name, name), object
+	names := make([]string, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		name := ctx.Name(fmt.Sprintf("variable_%s_%s", column, suffix))
+		object[name] = list.Index(i).Interface()
+		names[i] = ":" + name
+	}
+	return fmt.Sprintf("%s %s (%s)", columnName(column), operator, strings.Join(names, sqlComma)), object
+}
+
+// EXISTS Subquery
+type ExistsClause struct {
+	Query Clause
+	Not   bool
+}
+
+func Exists(query Clause) Clause {
+	return &ExistsClause{
+		Query: query,
+	}
+}
+
+func NotExists(query Clause) Clause {
+	return &ExistsClause{
+		Query: query,
+		Not:   true,
+	}
+}
+
+func (c *ExistsClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	stmt, obj := c.Query.Compile(ctx)
+	if c.Not {
+		return fmt.Sprintf("NOT EXISTS (%s)", stmt), obj
+	}
+	return fmt.Sprintf("EXISTS (%s)", stmt), obj
+}
+
+// Common Table Expression, named for the rest of the statement
+type CommonTable struct {
+	Name      string
+	Columns   []string
+	Query     Clause
+	Recursive bool
+}
+
+// WITH Clause, prefixed to a statement
+type WithClause []CommonTable
+
+func (c WithClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	outObj := make(map[string]interface{})
+	tables := make([]string, len(c))
+	recursive := false
+	for i, v := range c {
+		queryStmt, queryObj := v.Query.Compile(ctx)
+		outObj = mapUnion(outObj, queryObj)
+
+		name := v.Name
+		if len(v.Columns) > 0 {
+			columns := make([]string, len(v.Columns))
+			for j, column := range v.Columns {
+				columns[j] = quoteIdentifier(column)
+			}
+			name = fmt.Sprintf("%s (%s)", name, strings.Join(columns, sqlComma))
+		}
+		tables[i] = fmt.Sprintf("%s AS (%s)", name, queryStmt)
+		recursive = recursive || v.Recursive
+	}
+
+	// RECURSIVE applies to the whole list, and lets any table refer to itself.
+	if recursive {
+		return fmt.Sprintf("WITH RECURSIVE %s", strings.Join(tables, sqlComma)), outObj
+	}
+	return fmt.Sprintf("WITH %s", strings.Join(tables, sqlComma)), outObj
+}
+
+func (c WithClause) with(name string, query Clause, recursive bool) WithClause {
+	return append(append(WithClause(nil), c...), CommonTable{
+		Name:      name,
+		Query:     query,
+		Recursive: recursive,
+	})
+}
+
+// compileWith prefixes stmt with the compiled common tables, if any.
+func compileWith(ctx *CompileContext, c WithClause, compile func() (string, map[string]interface{})) (string, map[string]interface{}) {
+	if len(c) == 0 {
+		return compile()
+	}
+	withStmt, withObj := c.Compile(ctx)
+	stmt, obj := compile()
+	return fmt.Sprintf("%s %s", withStmt, stmt), mapUnion(withObj, obj)
+}
+
+// Raw SQL, with named parameters of its own. Parameters that are already in
+// use by the statement are renamed to keep them apart.
+type RawClause struct {
+	SQL    string
+	Params map[string]interface{}
+}
+
+func Raw(sql string, params map[string]interface{}) Clause {
+	return &RawClause{
+		SQL:    sql,
+		Params: params,
+	}
+}
+
+func (c *RawClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	object := make(map[string]interface{})
+	renames := make(map[string]string)
+	for key, value := range c.Params {
+		name := ctx.Name(key)
+		if name != key {
+			renames[key] = name
+		}
+		object[name] = value
+	}
+
+	return renameParameters(c.SQL, renames), object
+}
+
+// renameParameters rewrites the :name parameters of stmt and escapes each ::
+// (a Postgres cast) as ::::, which sqlx turns back into :: when binding.
+func renameParameters(stmt string, renames map[string]string) string {
+	out := make([]byte, 0, len(stmt))
+	for i := 0; i < len(stmt); i++ {
+		if stmt[i] != ':' {
+			out = append(out, stmt[i])
+			continue
+		}
+		if i+1 < len(stmt) && stmt[i+1] == ':' {
+			out = append(out, "::::"...)
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(stmt) && (stmt[end] == '_' || unicode.IsLetter(rune(stmt[end])) || unicode.IsDigit(rune(stmt[end]))) {
+			end++
+		}
+		name := stmt[i+1 : end]
+		if renamed, ok := renames[name]; ok {
+			name = renamed
+		}
+		out = append(out, ':')
+		out = append(out, name...)
+		i = end - 1
+	}
+	return string(out)
 }
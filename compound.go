@@ -0,0 +1,181 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Compound Operators
+const (
+	SetUnion     = "UNION"
+	SetUnionAll  = "UNION ALL"
+	SetIntersect = "INTERSECT"
+	SetExcept    = "EXCEPT"
+)
+
+type CompoundPart struct {
+	Operator string
+	Query    Clause
+}
+
+// A Compound Statement combines the rows of several queries, which must
+// select the same number of columns. Its ordering refers to the result
+// columns by name.
+type CompoundStatement struct {
+	First        Clause
+	Parts        []CompoundPart
+	OrderClauses []OrderClause
+	LimitClause  Clause
+	OffsetClause Clause
+}
+
+func (c *CompoundStatement) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	outStatement, outObjects := compileCompoundMember(ctx, c.First)
+	for _, v := range c.Parts {
+		partStmt, partObj := compileCompoundMember(ctx, v.Query)
+		outStatement = fmt.Sprintf("%s %s %s", outStatement, v.Operator, partStmt)
+		outObjects = mapUnion(outObjects, partObj)
+	}
+
+	orderStmt, orderObj := compileOrderLimit(ctx, c.OrderClauses, c.LimitClause, c.OffsetClause)
+	outStatement += orderStmt
+	outObjects = mapUnion(outObjects, orderObj)
+
+	return outStatement, outObjects
+}
+
+// SQLite doesn't accept parenthesized members, so members that carry their
+// own WITH, ORDER BY or LIMIT (or are compounds themselves) are selected from
+// as subqueries instead.
+func compileCompoundMember(ctx *CompileContext, query Clause) (string, map[string]interface{}) {
+	if ctx.Driver != "sqlite3" {
+		stmt, obj := query.Compile(ctx)
+		return fmt.Sprintf("(%s)", stmt), obj
+	}
+
+	if s, ok := query.(*SelectStatement); ok && len(s.CommonTables) == 0 && len(s.OrderClauses) == 0 && s.LimitClause == nil && s.OffsetClause == nil {
+		return query.Compile(ctx)
+	}
+
+	stmt, obj := query.Compile(ctx)
+	return fmt.Sprintf("SELECT * FROM (%s)", stmt), obj
+}
+
+// Clone copies the statement. As with SelectStatement, every builder method
+// works on a clone of its receiver.
+func (q *CompoundStatement) Clone() *CompoundStatement {
+	out := *q
+	out.Parts = append([]CompoundPart(nil), q.Parts...)
+	out.OrderClauses = append([]OrderClause(nil), q.OrderClauses...)
+	return &out
+}
+
+func (q *CompoundStatement) combine(operator string, query Clause) *CompoundStatement {
+	q = q.Clone()
+	q.Parts = append(q.Parts, CompoundPart{
+		Operator: operator,
+		Query:    query,
+	})
+	return q
+}
+
+func (q *CompoundStatement) Union(query Clause) *CompoundStatement {
+	return q.combine(SetUnion, query)
+}
+
+func (q *CompoundStatement) UnionAll(query Clause) *CompoundStatement {
+	return q.combine(SetUnionAll, query)
+}
+
+func (q *CompoundStatement) Intersect(query Clause) *CompoundStatement {
+	return q.combine(SetIntersect, query)
+}
+
+func (q *CompoundStatement) Except(query Clause) *CompoundStatement {
+	return q.combine(SetExcept, query)
+}
+
+func (q *CompoundStatement) Order(key string, ascending bool) *CompoundStatement {
+	return q.OrderNulls(key, ascending, NullsDefault)
+}
+
+func (q *CompoundStatement) OrderNulls(key string, ascending bool, nulls NullsOrder) *CompoundStatement {
+	q = q.Clone()
+	q.OrderClauses = append(q.OrderClauses, OrderClause{
+		Key:       key,
+		Ascending: ascending,
+		Nulls:     nulls,
+	})
+	return q
+}
+
+func (q *CompoundStatement) Limit(number int) *CompoundStatement {
+	q = q.Clone()
+	q.LimitClause = &LimitClause{
+		Number: number,
+	}
+	return q
+}
+
+func (q *CompoundStatement) Offset(number int) *CompoundStatement {
+	q = q.Clone()
+	q.OffsetClause = &OffsetClause{
+		Number: number,
+	}
+	return q
+}
+
+func (q *CompoundStatement) One(db Executor, object interface{}) error {
+	err := q.rejectPreloads()
+	if err != nil {
+		return err
+	}
+	return queryOne(db, q.Limit(1), object)
+}
+
+func (q *CompoundStatement) All(db Executor, object interface{}) error {
+	err := q.rejectPreloads()
+	if err != nil {
+		return err
+	}
+	return queryAll(db, q, object)
+}
+
+// Preloads of the member queries can't be applied to the combined rows.
+func (q *CompoundStatement) rejectPreloads() error {
+	queries := []Clause{q.First}
+	for _, v := range q.Parts {
+		queries = append(queries, v.Query)
+	}
+	return rejectPreloads("Preload is not supported by compound queries.", queries...)
+}
+
+func (c *CompoundStatement) Exec(db Executor) (sql.Result, error) {
+	return execute(db, c)
+}
+
+func (q *SelectStatement) compound(operator string, query Clause) *CompoundStatement {
+	return (&CompoundStatement{
+		First: q,
+	}).combine(operator, query)
+}
+
+// Union combines the distinct rows of q and query.
+func (q *SelectStatement) Union(query Clause) *CompoundStatement {
+	return q.compound(SetUnion, query)
+}
+
+// UnionAll combines every row of q and query, including duplicates.
+func (q *SelectStatement) UnionAll(query Clause) *CompoundStatement {
+	return q.compound(SetUnionAll, query)
+}
+
+// Intersect keeps the rows of q that query also returns.
+func (q *SelectStatement) Intersect(query Clause) *CompoundStatement {
+	return q.compound(SetIntersect, query)
+}
+
+// Except keeps the rows of q that query doesn't return.
+func (q *SelectStatement) Except(query Clause) *CompoundStatement {
+	return q.compound(SetExcept, query)
+}
@@ -113,14 +113,14 @@ func mapUnion(x map[string]interface{}, y map[string]interface{}) map[string]int
 }
 
 //
-func JoinClausesOn(c []Clause, on string) (string, map[string]interface{}) {
+func JoinClausesOn(ctx *CompileContext, c []Clause, on string) (string, map[string]interface{}) {
 	outStmt := ""
 	outObj := make(map[string]interface{})
 	for i, v := range c {
 		if i != 0 {
 			outStmt += on
 		}
-		tempStmt, tempObjects := v.Compile()
+		tempStmt, tempObjects := v.Compile(ctx)
 		outStmt += tempStmt
 		outObj = mapUnion(outObj, tempObjects)
 	}
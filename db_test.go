@@ -1,12 +1,17 @@
 package db
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 type Story struct {
@@ -24,6 +29,86 @@ type Author struct {
 	Stories *HasMany `table:"story" on:"author"`
 }
 
+type Tag struct {
+	Id   PrimaryKey
+	Name string
+}
+
+type Post struct {
+	Id   PrimaryKey
+	Tags *ManyToMany `table:"tag"`
+}
+
+type Comment struct {
+	Id     PrimaryKey
+	Body   string
+	Parent *Polymorphic
+}
+
+type Page struct {
+	Id       PrimaryKey
+	Title    string
+	Comments *HasMany `table:"comment" as:"parent"`
+}
+
+type Entry struct {
+	Id   PrimaryKey
+	Date time.Time
+	Name string
+}
+
+type Wiki struct {
+	Slug     StringKey
+	Comments *HasMany `table:"comment" as:"parent"`
+}
+
+type Account struct {
+	Id      UUIDKey
+	Name    string
+	Members *HasMany `table:"member" on:"account"`
+}
+
+type Member struct {
+	Id      StringKey
+	Account *HasOne `table:"account"`
+}
+
+type Reading struct {
+	Series string `primary:"true"`
+	Ts     int64  `primary:"true"`
+	Value  float64
+}
+
+type Note struct {
+	Id    PrimaryKey
+	Story *HasOne `table:"story" ondelete:"cascade" onupdate:"set_null"`
+}
+
+type BadNote struct {
+	Id    PrimaryKey
+	Story *HasOne `table:"story" ondelete:"explode"`
+}
+
+type Device struct {
+	Id  PrimaryKey
+	Ext UUID
+}
+
+type TenantItem struct {
+	TenantId int `primary:"true"`
+	Id       PrimaryKey
+	Name     string
+}
+
+type Stray struct {
+	Id    PrimaryKey
+	Owner *HasOne `table:"nowhere"`
+}
+
+type Keyless struct {
+	Name string
+}
+
 type Data struct {
 	Statement  string
 	Parameters map[string]interface{}
@@ -51,8 +136,28 @@ func (t *TestDb) NamedExec(query string, arg interface{}) (sql.Result, error) {
 	return TestResult{}, nil
 }
 
+// NamedQuery records the query and fails, as there are no rows to return.
 func (t *TestDb) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
-	return nil, nil
+	t.Data <- Data{
+		Statement:  query,
+		Parameters: arg.(map[string]interface{}),
+	}
+	return nil, errors.New("TestDb has no rows.")
+}
+
+func (t *TestDb) DriverName() string {
+	return "sqlite3"
+}
+
+// openSQLite opens an in-memory SQLite database on a single connection, for
+// tests that need real rows.
+func openSQLite(t *testing.T) *sqlx.DB {
+	conn, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	return conn
 }
 
 func TestSelect(t *testing.T) {
@@ -68,7 +173,7 @@ func TestSelect(t *testing.T) {
 	}
 
 	data := <-dataChan
-	if data.Statement != "CREATE TABLE  author (id integer, name text, CONSTRAINT author_pk PRIMARY KEY (id))" {
+	if data.Statement != `CREATE TABLE  author ("id" integer, "name" text, CONSTRAINT author_pk PRIMARY KEY (id))` {
 		t.Error("Creating Authors Table Incorrect SQL")
 	}
 	fmt.Println(data.Statement, data.Parameters)
@@ -80,7 +185,7 @@ func TestSelect(t *testing.T) {
 	}
 
 	data = <-dataChan
-	if data.Statement != "CREATE TABLE IF NOT EXISTS story (id integer, name text, body text, slug text, author integer, CONSTRAINT story_pk PRIMARY KEY (id))" {
+	if data.Statement != `CREATE TABLE IF NOT EXISTS story ("id" integer, "name" text, "body" text, "slug" text, "slug_body" text, "author" integer, CONSTRAINT story_pk PRIMARY KEY (id), FOREIGN KEY (author) REFERENCES author(id))` {
 		t.Error("Creating Stories Table Incorrect SQL")
 	}
 	fmt.Println(data.Statement, data.Parameters)
@@ -99,7 +204,7 @@ func TestSelect(t *testing.T) {
 	}
 
 	data = <-dataChan
-	if data.Statement != "INSERT INTO author (name) VALUES (:name)" {
+	if data.Statement != `INSERT INTO author ("name") VALUES (:name)` {
 		t.Error("Inserting Author Incorrect SQL")
 	}
 	fmt.Println(data.Statement, data.Parameters)
@@ -147,7 +252,7 @@ func TestSelect(t *testing.T) {
 	}
 
 	data = <-dataChan
-	if data.Statement != "SELECT * FROM story WHERE (slug = :variable_slug AND author = :variable_author) ORDER BY slug ASC LIMIT 5" {
+	if data.Statement != `SELECT "id", "name", "body", "slug", "slug_body", "author" FROM story WHERE ("slug" = :variable_slug AND "author" = :variable_author) ORDER BY slug ASC LIMIT 5` {
 		t.Error("Selecting Story Incorrect SQL")
 	}
 	fmt.Println(data.Statement, data.Parameters)
@@ -159,7 +264,7 @@ func TestSelect(t *testing.T) {
 	}
 
 	data = <-dataChan
-	if data.Statement != "SELECT * FROM author WHERE (id = :variable_id)" {
+	if data.Statement != `SELECT "id", "name" FROM author WHERE ("id" = :variable_id)` {
 		t.Error("Selecting Author Through Relationship Incorrect SQL")
 	}
 	fmt.Println(data.Statement, data.Parameters)
@@ -171,7 +276,7 @@ func TestSelect(t *testing.T) {
 	}
 
 	data = <-dataChan
-	if data.Statement != "SELECT * FROM story WHERE (author = :variable_author) LIMIT 2" {
+	if data.Statement != `SELECT "id", "name", "body", "slug", "slug_body", "author" FROM story WHERE ("author" = :variable_author) LIMIT 2` {
 		t.Error("Selecting Stories Through Relationship Incorrect SQL")
 	}
 	fmt.Println(data.Statement, data.Parameters)
@@ -183,8 +288,1426 @@ func TestSelect(t *testing.T) {
 	}
 
 	data = <-dataChan
-	if data.Statement != "DELETE FROM author WHERE id = :variable_id" {
+	if data.Statement != `DELETE FROM author WHERE "id" = :variable_id` {
 		t.Error("Deleting Author Incorrect SQL")
 	}
 	fmt.Println(data.Statement, data.Parameters)
 }
+
+func TestComparisons(t *testing.T) {
+	stmt, obj := (&SelectStatement{
+		Table: "story",
+	}).WhereGreaterEqual("views", 100).WhereLess("views", 200).WhereNotEqual("slug", "draft").WhereBetween("created", 1, 5).WhereLike("name", "%beach%").WhereNotNull("body").Compile(NewCompileContext())
+
+	if stmt != `SELECT * FROM story WHERE ("views" >= :variable_views_gte AND "views" < :variable_views_lt AND "slug" <> :variable_slug_ne AND "created" BETWEEN :variable_created_low AND :variable_created_high AND "name" LIKE :variable_name_like AND "body" IS NOT NULL)` {
+		t.Error("Comparison Incorrect SQL")
+	}
+	if len(obj) != 6 || obj["variable_views_gte"] != 100 || obj["variable_created_high"] != 5 {
+		t.Error("Comparison Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	ctx := NewCompileContext()
+	stmt, _ = (&SelectStatement{
+		Table: "story",
+	}).WhereOp("name", "!=", "a").Compile(ctx)
+	if stmt != `SELECT * FROM story WHERE ("name" <> :variable_name_ne)` || ctx.Err() != nil {
+		t.Error("Not Equal Alias Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	ctx = NewCompileContext()
+	(&SelectStatement{
+		Table: "story",
+	}).WhereOp("name", "===", "a").Having(Compare(Count("*"), "~", 1)).Compile(ctx)
+	if ctx.Err() == nil {
+		t.Error("Unknown Operator Accepted")
+	}
+
+	ctx = NewCompileContext()
+	ctx.Driver = "sqlite3"
+	(&SelectStatement{
+		Table: "story",
+	}).WhereILike("name", "%beach%").Compile(ctx)
+	if ctx.Err() == nil {
+		t.Error("ILike Accepted By SQLite")
+	}
+}
+
+func TestIn(t *testing.T) {
+	stmt, obj := (&SelectStatement{
+		Table: "story",
+	}).WhereIn("id", []int{1, 2, 3}).WhereNotIn("author", []string{"a"}).WhereIn("slug", []string{}).Compile(NewCompileContext())
+
+	if stmt != `SELECT * FROM story WHERE ("id" IN (:variable_id_in, :variable_id_in_1, :variable_id_in_2) AND "author" NOT IN (:variable_author_not_in) AND 1 = 0)` {
+		t.Error("In Incorrect SQL")
+	}
+	if len(obj) != 4 || obj["variable_id_in_2"] != 3 || obj["variable_author_not_in"] != "a" {
+		t.Error("In Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	stmt, _ = OrClauses{&NotIn{Name: "id", Values: nil}, &In{Name: "id", Values: 5}}.Compile(NewCompileContext())
+	if stmt != "1 = 1 OR \"id\" IN (:variable_id_in)" {
+		t.Error("Empty Not In Incorrect SQL")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	stmt, obj := (&SelectStatement{
+		Table: "story",
+	}).WhereGroup(func(g *Group) {
+		g.Where("name", "a").OrWhere("slug", "b")
+	}).Where("author", 3).Compile(NewCompileContext())
+
+	if stmt != `SELECT * FROM story WHERE (("name" = :variable_name OR "slug" = :variable_slug) AND "author" = :variable_author)` {
+		t.Error("Group Incorrect SQL")
+	}
+	if len(obj) != 3 {
+		t.Error("Group Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	// Mixing AND and OR on the statement itself never panics.
+	stmt, _ = (&SelectStatement{
+		Table: "story",
+	}).Where("name", "a").OrWhere("slug", "b").Where("author", 3).WhereNot(&NamedNull{Name: "body"}).Compile(NewCompileContext())
+
+	if stmt != `SELECT * FROM story WHERE (("name" = :variable_name OR "slug" = :variable_slug) AND "author" = :variable_author AND NOT ("body" IS NULL))` {
+		t.Error("Mixed Group Incorrect SQL")
+	}
+	fmt.Println(stmt)
+}
+
+func TestParameterNames(t *testing.T) {
+	stmt, obj := (&SelectStatement{
+		Table: "story",
+	}).WhereGreater("date", 1).WhereGreater("date", 2).Where("author", 1).OrWhereGroup(func(g *Group) {
+		g.Where("author", 2).Where("slug", "a")
+	}).Compile(NewCompileContext())
+
+	if stmt != `SELECT * FROM story WHERE (("date" > :variable_date_gt AND "date" > :variable_date_gt_1 AND "author" = :variable_author) OR ("author" = :variable_author_1 AND "slug" = :variable_slug))` {
+		t.Error("Parameter Names Incorrect SQL")
+	}
+	if obj["variable_author"] != 1 || obj["variable_author_1"] != 2 || obj["variable_date_gt_1"] != 2 {
+		t.Error("Parameter Names Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	// Update shares one namespace between SET and WHERE.
+	stmt, obj = (&UpdateStatement{
+		Table:   "story",
+		Where:   &NamedEquality{Name: "slug", Value: "old"},
+		Columns: SetClause{&NamedEquality{Name: "slug", Value: "new"}},
+	}).Compile(NewCompileContext())
+
+	if stmt != `UPDATE story SET "slug" = :variable_slug_1 WHERE "slug" = :variable_slug` {
+		t.Error("Update Parameter Names Incorrect SQL")
+	}
+	if obj["variable_slug"] != "old" || obj["variable_slug_1"] != "new" {
+		t.Error("Update Parameter Names Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+}
+
+func TestProjection(t *testing.T) {
+	stmt, _ := (&SelectStatement{
+		Table: "story",
+	}).Columns("id", "story.name").AddSelect(As(Expression("LENGTH(body)"), "body_length")).Compile(NewCompileContext())
+
+	if stmt != `SELECT "id", "story"."name", LENGTH(body) AS "body_length" FROM story` {
+		t.Error("Projection Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	table := BasicTable{
+		TableName: "story",
+		Fieldset:  []Field{{Name: "id"}, {Name: "name"}},
+	}
+	stmt, _ = table.Get().Compile(NewCompileContext())
+	if stmt != `SELECT "id", "name" FROM story` {
+		t.Error("Table Projection Incorrect SQL")
+	}
+	fmt.Println(stmt)
+}
+
+func TestOrderAndOffset(t *testing.T) {
+	query := (&SelectStatement{
+		Table: "story",
+	}).Order("date", false).OrderNulls("views", true, NullsLast).Order("id", true).Offset(20)
+
+	ctx := NewCompileContext()
+	ctx.Driver = "postgres"
+	stmt, _ := query.Compile(ctx)
+	if stmt != "SELECT * FROM story ORDER BY date DESC, views ASC NULLS LAST, id ASC OFFSET 20" {
+		t.Error("Order Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	ctx = NewCompileContext()
+	ctx.Driver = "sqlite3"
+	stmt, _ = query.Limit(10).Compile(ctx)
+	if stmt != "SELECT * FROM story ORDER BY date DESC, views ASC NULLS LAST, id ASC LIMIT 10 OFFSET 20" {
+		t.Error("Order With Limit Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	ctx = NewCompileContext()
+	ctx.Driver = "sqlite3"
+	stmt, _ = (&SelectStatement{
+		Table: "story",
+	}).OrderNulls("views", false, NullsFirst).Offset(5).Compile(ctx)
+	if stmt != "SELECT * FROM story ORDER BY views DESC NULLS FIRST LIMIT -1 OFFSET 5" {
+		t.Error("Offset Without Limit Incorrect SQL")
+	}
+	fmt.Println(stmt)
+}
+
+func TestKeyset(t *testing.T) {
+	cursor, err := ParseCursor(Cursor{"2014-01-01", 5}.String())
+	if err != nil || len(cursor) != 2 || cursor[0] != "2014-01-01" || cursor[1] != int64(5) {
+		t.Error("Cursor Incorrect Round Trip")
+	}
+
+	stmt, obj := (&SelectStatement{
+		Table: "story",
+	}).Order("date", false).Order("id", false).After(cursor).Compile(NewCompileContext())
+
+	if stmt != "SELECT * FROM story WHERE ((date, id) < (:variable_cursor, :variable_cursor_1)) ORDER BY date DESC, id DESC" {
+		t.Error("Keyset Incorrect SQL")
+	}
+	if obj["variable_cursor_1"] != int64(5) {
+		t.Error("Keyset Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	stmt, _ = (&SelectStatement{
+		Table: "story",
+	}).Order("date", false).Order("id", true).After(cursor).Compile(NewCompileContext())
+
+	if stmt != "SELECT * FROM story WHERE (date < :variable_cursor OR (date = :variable_cursor AND id > :variable_cursor_1)) ORDER BY date DESC, id ASC" {
+		t.Error("Mixed Keyset Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	date := time.Date(2024, 1, 1, 16, 0, 0, 500, time.UTC)
+	typed, err := ParseCursor(Cursor{date, []byte{0, 255}}.String())
+	if err != nil || len(typed) != 2 || !date.Equal(typed[0].(time.Time)) || !bytes.Equal(typed[1].([]byte), []byte{0, 255}) {
+		t.Error("Typed Cursor Incorrect Round Trip")
+	}
+
+	if _, err := ParseCursor("not a cursor"); err == nil {
+		t.Error("Invalid Cursor Accepted")
+	}
+
+	ctx := NewCompileContext()
+	(&SelectStatement{
+		Table: "story",
+	}).Order("date", false).After(cursor).Compile(ctx)
+	if ctx.Err() == nil {
+		t.Error("Mismatched Cursor Accepted")
+	}
+
+	ctx = NewCompileContext()
+	(&SelectStatement{
+		Table: "story",
+	}).OrderNulls("date", false, NullsLast).Order("id", false).After(cursor).Compile(ctx)
+	if ctx.Err() == nil {
+		t.Error("Nulls Ordered Keyset Accepted")
+	}
+
+	query := (&SelectStatement{
+		Table: "story",
+	}).Order("id", true)
+	if _, err := query.Paginate(nil, 0, nil, &[]Story{}); err == nil {
+		t.Error("Zero Page Limit Accepted")
+	}
+	if _, err := query.OrderNulls("date", true, NullsFirst).Paginate(nil, 10, nil, &[]Story{}); err == nil {
+		t.Error("Nulls Ordered Pagination Accepted")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	table := BasicTable{
+		TableName: "story",
+		Fieldset:  []Field{{Name: "id"}, {Name: "name"}},
+	}
+	stmt, obj := table.Get().As("s").Join("author", "a", AndClauses{
+		On("s.author", "a.id"),
+		&NamedEquality{Name: "a.name", Value: "Hunter"},
+	}).LeftJoin("tag", "", On("tag.story", "s.id")).AddSelect(Nested("a", "writer", "name")...).Where("s.slug", "a").Compile(NewCompileContext())
+
+	if stmt != `SELECT "s"."id", "s"."name", "a"."name" AS "writer.name" FROM story AS s INNER JOIN author AS a ON ("s"."author" = "a"."id" AND "a"."name" = :variable_a_name) LEFT JOIN tag ON ("tag"."story" = "s"."id") WHERE ("s"."slug" = :variable_s_slug)` {
+		t.Error("Join Incorrect SQL")
+	}
+	if obj["variable_a_name"] != "Hunter" || obj["variable_s_slug"] != "a" {
+		t.Error("Join Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	stmt, _ = (&SelectStatement{
+		Table: "story",
+	}).CrossJoin("author", "a").Compile(NewCompileContext())
+	if stmt != "SELECT * FROM story CROSS JOIN author AS a" {
+		t.Error("Cross Join Incorrect SQL")
+	}
+}
+
+func TestAggregates(t *testing.T) {
+	stmt, obj := (&SelectStatement{
+		Table: "story",
+	}).Columns("author").AddSelect(Count("*").As("total"), CountDistinct("slug"), Max("views")).Where("name", "a").GroupBy("author").Having(Compare(Count("*"), OpGreater, 5)).Order("total", false).Compile(NewCompileContext())
+
+	if stmt != `SELECT "author", COUNT(*) AS "total", COUNT(DISTINCT "slug"), MAX("views") FROM story WHERE ("name" = :variable_name) GROUP BY "author" HAVING (COUNT(*) > :variable_value) ORDER BY total DESC` {
+		t.Error("Aggregate Incorrect SQL")
+	}
+	if obj["variable_value"] != 5 {
+		t.Error("Aggregate Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+}
+
+func TestTerminals(t *testing.T) {
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+	query := (&SelectStatement{
+		Table: "story",
+	}).Columns("id", "name").Where("author", 5).Order("date", false)
+
+	query.Count(connection)
+	data := <-dataChan
+	if data.Statement != `SELECT COUNT(*) FROM story WHERE ("author" = :variable_author)` {
+		t.Error("Count Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	(&SelectStatement{
+		Table: "story",
+	}).Columns("author").GroupBy("author").Count(connection)
+	data = <-dataChan
+	if data.Statement != `SELECT COUNT(*) FROM (SELECT "author" FROM story GROUP BY "author") AS counted` {
+		t.Error("Grouped Count Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	(&SelectStatement{
+		Table: "story",
+	}).Distinct().Columns("author").Count(connection)
+	data = <-dataChan
+	if data.Statement != `SELECT COUNT(*) FROM (SELECT DISTINCT "author" FROM story) AS counted` {
+		t.Error("Distinct Count Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	query.Exists(connection)
+	data = <-dataChan
+	if data.Statement != `SELECT EXISTS (SELECT 1 FROM story WHERE ("author" = :variable_author) LIMIT 1)` {
+		t.Error("Exists Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	slugs := []string{}
+	query.Pluck(connection, "slug", &slugs)
+	data = <-dataChan
+	if data.Statement != `SELECT "slug" FROM story WHERE ("author" = :variable_author) ORDER BY date DESC` {
+		t.Error("Pluck Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+}
+
+func TestSubqueries(t *testing.T) {
+	authors := (&SelectStatement{
+		Table: "author",
+	}).Columns("id").WhereLike("name", "H%")
+
+	stmt, obj := (&SelectStatement{
+		Table: "story",
+	}).Where("name", "a").WhereIn("author", authors).WhereNotExists((&SelectStatement{
+		Table: "tag",
+	}).Where("name", "b")).WhereOp("views", OpGreater, (&SelectStatement{
+		Table: "story",
+	}).AddSelect(Avg("views"))).Compile(NewCompileContext())
+
+	if stmt != `SELECT * FROM story WHERE ("name" = :variable_name AND "author" IN (SELECT "id" FROM author WHERE ("name" LIKE :variable_name_like)) AND NOT EXISTS (SELECT * FROM tag WHERE ("name" = :variable_name_1)) AND "views" > (SELECT AVG("views") FROM story))` {
+		t.Error("Subquery Incorrect SQL")
+	}
+	if obj["variable_name"] != "a" || obj["variable_name_like"] != "H%" || obj["variable_name_1"] != "b" {
+		t.Error("Subquery Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	stmt, _ = (&SelectStatement{}).From(authors, "a").Where("a.id", 1).Compile(NewCompileContext())
+	if stmt != `SELECT * FROM (SELECT "id" FROM author WHERE ("name" LIKE :variable_name_like)) AS a WHERE ("a"."id" = :variable_a_id)` {
+		t.Error("Derived Table Incorrect SQL")
+	}
+	fmt.Println(stmt)
+}
+
+func TestCommonTables(t *testing.T) {
+	popular := (&SelectStatement{
+		Table: "story",
+	}).Columns("author").WhereGreater("views", 100)
+
+	stmt, obj := (&SelectStatement{
+		Table: "author",
+	}).With("popular", popular).WhereIn("id", (&SelectStatement{
+		Table: "popular",
+	}).Columns("author")).Where("name", "a").Compile(NewCompileContext())
+
+	if stmt != `WITH popular AS (SELECT "author" FROM story WHERE ("views" > :variable_views_gt)) SELECT * FROM author WHERE ("id" IN (SELECT "author" FROM popular) AND "name" = :variable_name)` {
+		t.Error("Common Table Incorrect SQL")
+	}
+	if obj["variable_views_gt"] != 100 || obj["variable_name"] != "a" {
+		t.Error("Common Table Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	tree := Expression(`SELECT "id" FROM category WHERE "id" = 1 UNION ALL SELECT category."id" FROM category INNER JOIN tree ON category."parent" = tree."id"`)
+	stmt, _ = (&DeleteStatement{
+		Table: "category",
+		Where: &In{Name: "id", Values: (&SelectStatement{Table: "tree"}).Columns("id")},
+	}).WithRecursive("tree", tree).Compile(NewCompileContext())
+
+	if stmt != `WITH RECURSIVE tree AS (`+string(tree)+`) DELETE FROM category WHERE "id" IN (SELECT "id" FROM tree)` {
+		t.Error("Recursive Common Table Incorrect SQL")
+	}
+	fmt.Println(stmt)
+}
+
+func TestCompound(t *testing.T) {
+	stories := (&SelectStatement{
+		Table: "story",
+	}).Columns("name", "date").Where("author", 1)
+	comments := (&SelectStatement{
+		Table: "comment",
+	}).Columns("body", "date").Where("author", 1)
+
+	recent := (&SelectStatement{
+		Table: "comment",
+	}).Columns("body", "date").Where("author", 1).Limit(5)
+
+	query := stories.UnionAll(comments).Except(recent).Order("date", false).Limit(10)
+
+	ctx := NewCompileContext()
+	ctx.Driver = "postgres"
+	stmt, obj := query.Compile(ctx)
+	if stmt != `(SELECT "name", "date" FROM story WHERE ("author" = :variable_author)) UNION ALL (SELECT "body", "date" FROM comment WHERE ("author" = :variable_author_1)) EXCEPT (SELECT "body", "date" FROM comment WHERE ("author" = :variable_author_2) LIMIT 5) ORDER BY date DESC LIMIT 10` {
+		t.Error("Compound Incorrect SQL")
+	}
+	if len(obj) != 3 {
+		t.Error("Compound Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	ctx = NewCompileContext()
+	ctx.Driver = "sqlite3"
+	stmt, _ = query.Compile(ctx)
+	if stmt != `SELECT "name", "date" FROM story WHERE ("author" = :variable_author) UNION ALL SELECT "body", "date" FROM comment WHERE ("author" = :variable_author_1) EXCEPT SELECT * FROM (SELECT "body", "date" FROM comment WHERE ("author" = :variable_author_2) LIMIT 5) ORDER BY date DESC LIMIT 10` {
+		t.Error("SQLite Compound Incorrect SQL")
+	}
+	fmt.Println(stmt)
+}
+
+func TestDistinctAndWindows(t *testing.T) {
+	stmt, _ := (&SelectStatement{
+		Table: "story",
+	}).Distinct().Columns("author").Compile(NewCompileContext())
+	if stmt != `SELECT DISTINCT "author" FROM story` {
+		t.Error("Distinct Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	// Latest three stories per author.
+	ranked := (&SelectStatement{
+		Table: "story",
+	}).Columns("id", "author").AddSelect(RowNumber().PartitionBy("author").OrderBy("date", false).As("rank"))
+	stmt, obj := (&SelectStatement{}).From(ranked, "ranked").WhereLessEqual("rank", 3).Compile(NewCompileContext())
+
+	if stmt != `SELECT * FROM (SELECT "id", "author", ROW_NUMBER() OVER (PARTITION BY "author" ORDER BY date DESC) AS "rank" FROM story) AS ranked WHERE ("rank" <= :variable_rank_lte)` {
+		t.Error("Window Incorrect SQL")
+	}
+	if obj["variable_rank_lte"] != 3 {
+		t.Error("Window Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	ctx := NewCompileContext()
+	ctx.Driver = "postgres"
+	stmt, _ = (&SelectStatement{
+		Table: "story",
+	}).DistinctOn("author").Order("author", true).Order("date", false).Compile(ctx)
+	if stmt != `SELECT DISTINCT ON ("author") * FROM story ORDER BY author ASC, date DESC` || ctx.Err() != nil {
+		t.Error("Distinct On Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	ctx = NewCompileContext()
+	(&SelectStatement{
+		Table: "story",
+	}).DistinctOn("author").Compile(ctx)
+	if ctx.Err() != nil {
+		t.Error("Distinct On Rejected By Unknown Driver")
+	}
+
+	ctx = NewCompileContext()
+	ctx.Driver = "sqlite3"
+	(&SelectStatement{
+		Table: "story",
+	}).DistinctOn("author").Compile(ctx)
+	if ctx.Err() == nil {
+		t.Error("Distinct On Accepted By SQLite")
+	}
+}
+
+func TestRaw(t *testing.T) {
+	stmt, obj := (&SelectStatement{
+		Table: "story",
+	}).AddSelect(Raw("date::date AS day", nil)).Where("slug", "a").WhereRaw("LENGTH(slug) > :variable_slug", map[string]interface{}{
+		"variable_slug": 5,
+	}).Compile(NewCompileContext())
+
+	if stmt != `SELECT date::::date AS day FROM story WHERE ("slug" = :variable_slug AND (LENGTH(slug) > :variable_slug_1))` {
+		t.Error("Raw Incorrect SQL")
+	}
+	if obj["variable_slug"] != "a" || obj["variable_slug_1"] != 5 {
+		t.Error("Raw Incorrect Parameters")
+	}
+	fmt.Println(stmt, obj)
+
+	// Casts reach the database intact once sqlx binds the parameters.
+	bound, _, err := sqlx.Named(stmt, obj)
+	if err != nil || bound != `SELECT date::date AS day FROM story WHERE ("slug" = ? AND (LENGTH(slug) > ?))` {
+		t.Error("Raw Cast Not Preserved", bound)
+	}
+
+	stmt, _ = (&SelectStatement{
+		Table: "story",
+	}).WhereRaw("x = 1 OR y = 2", nil).Where("z", 3).Compile(NewCompileContext())
+	if stmt != `SELECT * FROM story WHERE ((x = 1 OR y = 2) AND "z" = :variable_z)` {
+		t.Error("Raw Precedence Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	stmt, _ = (&SelectStatement{
+		Table: "story",
+	}).Where("z", 3).WhereClauseOr(Raw("x = 1 AND y = 2", nil)).Compile(NewCompileContext())
+	if stmt != `SELECT * FROM story WHERE ("z" = :variable_z OR (x = 1 AND y = 2))` {
+		t.Error("Raw Predicate Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+	err = Query(connection, "SELECT * FROM story WHERE author = :author", map[string]interface{}{
+		"author": 5,
+	}).All(&[]Story{})
+	if err == nil {
+		t.Error("Raw Query Ignored Error")
+	}
+
+	data := <-dataChan
+	if data.Statement != "SELECT * FROM story WHERE author = :author" || data.Parameters["author"] != 5 {
+		t.Error("Raw Query Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+}
+
+func TestLocking(t *testing.T) {
+	ctx := NewCompileContext()
+	ctx.Driver = "postgres"
+	stmt, _ := (&SelectStatement{
+		Table: "job",
+	}).Where("state", "queued").Order("id", true).Limit(1).SkipLocked().Compile(ctx)
+
+	if stmt != `SELECT * FROM job WHERE ("state" = :variable_state) ORDER BY id ASC LIMIT 1 FOR UPDATE SKIP LOCKED` || ctx.Err() != nil {
+		t.Error("Locking Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	stmt, _ = (&SelectStatement{
+		Table: "job",
+	}).NoWait().ForShare().Compile(NewCompileContext())
+	if stmt != `SELECT * FROM job FOR SHARE NOWAIT` {
+		t.Error("Share Locking Incorrect SQL")
+	}
+
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+	_, err := (&SelectStatement{
+		Table: "job",
+	}).ForUpdate().Exec(connection)
+	if err == nil {
+		t.Error("Locking Accepted By SQLite")
+	}
+	if len(dataChan) != 0 {
+		t.Error("Locking Executed On SQLite")
+	}
+}
+
+func TestClone(t *testing.T) {
+	base := (&SelectStatement{
+		Table: "story",
+	}).Where("author", 1)
+
+	ordered := base.Order("date", false).Limit(5)
+	filtered := base.Where("slug", "a")
+
+	stmt, _ := base.Compile(NewCompileContext())
+	if stmt != `SELECT * FROM story WHERE ("author" = :variable_author)` {
+		t.Error("Base Query Modified")
+	}
+	stmt, _ = ordered.Compile(NewCompileContext())
+	if stmt != `SELECT * FROM story WHERE ("author" = :variable_author) ORDER BY date DESC LIMIT 5` {
+		t.Error("Ordered Query Incorrect SQL")
+	}
+	stmt, _ = filtered.Compile(NewCompileContext())
+	if stmt != `SELECT * FROM story WHERE ("author" = :variable_author AND "slug" = :variable_slug)` {
+		t.Error("Filtered Query Incorrect SQL")
+	}
+
+	// Relationship queries are never changed by using them.
+	Register("story", &Story{})
+	author := &Author{}
+	loadRelationships(author, int64(5))
+	author.Stories.Order("views", true)
+	author.Stories.One(&TestDb{Data: make(chan Data, 1)}, &Story{})
+
+	stmt, _ = author.Stories.Compile(NewCompileContext())
+	if stmt != `SELECT "id", "name", "body", "slug", "slug_body", "author" FROM story WHERE ("author" = :variable_author)` {
+		t.Error("Relationship Query Modified")
+	}
+	fmt.Println(stmt)
+}
+
+func TestIteration(t *testing.T) {
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+	query := (&SelectStatement{
+		Table: "story",
+	}).Where("author", 5)
+
+	if err := query.Each(connection, func(s Story) error { return nil }); err == nil {
+		t.Error("Each Accepted Non-Pointer Callback")
+	}
+
+	if err := query.Each(connection, func(s *Story) error { return nil }); err == nil {
+		t.Error("Each Ignored Error")
+	}
+	data := <-dataChan
+	if data.Statement != `SELECT * FROM story WHERE ("author" = :variable_author)` {
+		t.Error("Each Incorrect SQL")
+	}
+
+	for story, err := range Iterate[Story](connection, query) {
+		if story != nil || err == nil {
+			t.Error("Iterate Ignored Error")
+		}
+	}
+	<-dataChan
+}
+
+func TestAllRelationships(t *testing.T) {
+	Register("story", &Story{})
+	Register("author", &Author{})
+
+	values := []Story{{Id: 1, Author: &HasOne{Value: 3}}, {Id: 2}}
+	loadAllRelationships(&values)
+
+	pointers := []*Author{{Id: 4}, nil}
+	loadAllRelationships(&pointers)
+
+	if values[0].Author == nil || values[1].Author == nil || values[0].Author.Value != 3 {
+		t.Error("Value Slice Relationships Not Loaded")
+	}
+	if pointers[0].Stories == nil {
+		t.Error("Pointer Slice Relationships Not Loaded")
+	}
+
+	stmt, _ := values[0].Author.Compile(NewCompileContext())
+	if stmt != `SELECT "id", "name" FROM author WHERE ("id" = :variable_id)` {
+		t.Error("Loaded HasOne Incorrect SQL")
+	}
+	stmt, obj := pointers[0].Stories.Compile(NewCompileContext())
+	if stmt != `SELECT "id", "name", "body", "slug", "slug_body", "author" FROM story WHERE ("author" = :variable_author)` || obj["variable_author"] != int64(4) {
+		t.Error("Loaded HasMany Incorrect SQL")
+	}
+	fmt.Println(stmt, obj)
+}
+
+func TestPreload(t *testing.T) {
+	Register("story", Story{})
+	Register("author", &Author{})
+
+	base := &SelectStatement{Table: "story"}
+	if q := base.Preload("Author", "Author.Stories"); len(q.Preloads) != 2 || len(base.Preloads) != 0 {
+		t.Error("Preload Modified Original")
+	}
+
+	stories := []*Story{{Id: 1, Author: &HasOne{Value: 3}}, {Id: 2, Author: &HasOne{Value: 3}}, {Id: 5, Author: &HasOne{Value: 9}}}
+
+	queries := make([]string, 0)
+	fetch := func(query *SelectStatement, object interface{}) error {
+		stmt, obj := query.Compile(NewCompileContext())
+		queries = append(queries, stmt)
+		fmt.Println(stmt, obj)
+
+		switch out := object.(type) {
+		case *[]*Author:
+			*out = []*Author{{Id: 3, Name: "Hunter", Stories: &HasMany{}}}
+		case *[]*Story:
+			*out = []*Story{{Id: 1, Author: &HasOne{Value: 3}}, {Id: 2, Author: &HasOne{Value: 3}}}
+		}
+		return nil
+	}
+
+	err := preload(modelsOf(reflect.ValueOf(&stories)), []string{"Author", "Author.Stories"}, fetch)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(queries) != 2 ||
+		queries[0] != `SELECT "id", "name" FROM author WHERE ("id" IN (:variable_id_in, :variable_id_in_1))` ||
+		queries[1] != `SELECT "id", "name", "body", "slug", "slug_body", "author" FROM story WHERE ("author" IN (:variable_author_in))` {
+		t.Error("Preload Incorrect SQL")
+	}
+
+	author, ok := LoadedOne[Author](stories[0].Author)
+	if !ok || author == nil || author.Name != "Hunter" {
+		t.Error("Preloaded HasOne Not Attached")
+	}
+	if other, _ := LoadedOne[Author](stories[1].Author); other != author {
+		t.Error("Preloaded HasOne Not Shared")
+	}
+	if missing, ok := LoadedOne[Author](stories[2].Author); !ok || missing != nil {
+		t.Error("Preloaded HasOne Without Row Incorrect")
+	}
+	if written, ok := LoadedMany[Story](author.Stories); !ok || len(written) != 2 {
+		t.Error("Preloaded HasMany Not Attached")
+	}
+
+	stories[0].Author.Set(&Author{Id: 4})
+	if _, ok := stories[0].Author.Loaded(); ok {
+		t.Error("Set Kept Preloaded Value")
+	}
+
+	if preload(modelsOf(reflect.ValueOf(&stories)), []string{"Name"}, fetch) == nil {
+		t.Error("Preloaded Non-Relationship")
+	}
+}
+
+func TestManyToMany(t *testing.T) {
+	dataChan := make(chan Data, 2)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+
+	Register("tag", &Tag{})
+	_, err := CreateTableFromStruct("post", connection, false, &Post{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := <-dataChan; data.Statement != `CREATE TABLE IF NOT EXISTS post ("id" integer, CONSTRAINT post_pk PRIMARY KEY (id))` {
+		t.Error("Creating Posts Table Incorrect SQL")
+	}
+	data := <-dataChan
+	if data.Statement != `CREATE TABLE IF NOT EXISTS post_tag ("post" integer, "tag" integer, CONSTRAINT post_tag_pk PRIMARY KEY (post, tag), FOREIGN KEY (post) REFERENCES post(id) ON DELETE CASCADE, FOREIGN KEY (tag) REFERENCES tag(id) ON DELETE CASCADE)` {
+		t.Error("Creating Link Table Incorrect SQL")
+	}
+	fmt.Println(data.Statement)
+
+	post := &Post{Id: 7}
+	loadRelationships(post, nil)
+	stmt, obj := post.Tags.Compile(NewCompileContext())
+	if stmt != `SELECT "tag"."id", "tag"."name" FROM tag INNER JOIN post_tag ON ("post_tag"."tag" = "tag"."id") WHERE ("post_tag"."post" = :variable_post_tag_post)` || obj["variable_post_tag_post"] != int64(7) {
+		t.Error("ManyToMany Incorrect SQL")
+	}
+	fmt.Println(stmt, obj)
+
+	err = post.Tags.Add(connection, &Tag{Id: 2})
+	if err != nil {
+		t.Error(err)
+	}
+	data = <-dataChan
+	if !strings.HasPrefix(data.Statement, "INSERT INTO post_tag") || data.Parameters["post"] != int64(7) || data.Parameters["tag"] != int64(2) {
+		t.Error("ManyToMany Add Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	err = post.Tags.Remove(connection, &Tag{Id: 2}, &Tag{Id: 3})
+	if err != nil {
+		t.Error(err)
+	}
+	data = <-dataChan
+	if data.Statement != `DELETE FROM post_tag WHERE "post" = :variable_post AND "tag" IN (:variable_tag_in, :variable_tag_in_1)` {
+		t.Error("ManyToMany Remove Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	err = post.Tags.Replace(connection, &Tag{Id: 4})
+	if err != nil {
+		t.Error(err)
+	}
+	if data = <-dataChan; data.Statement != `DELETE FROM post_tag WHERE "post" = :variable_post` {
+		t.Error("ManyToMany Clear Incorrect SQL")
+	}
+	if data = <-dataChan; data.Parameters["tag"] != int64(4) {
+		t.Error("ManyToMany Replace Incorrect SQL")
+	}
+}
+
+func TestPolymorphic(t *testing.T) {
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+
+	comments, err := CreateTableFromStruct("comment", connection, false, &Comment{})
+	if err != nil {
+		t.Error(err)
+	}
+	data := <-dataChan
+	if data.Statement != `CREATE TABLE IF NOT EXISTS comment ("id" integer, "body" text, "parent_type" text, "parent_id" text, CONSTRAINT comment_pk PRIMARY KEY (id))` {
+		t.Error("Creating Comments Table Incorrect SQL")
+	}
+	fmt.Println(data.Statement)
+
+	Register("page", &Page{})
+	comment := &Comment{Body: "First!", Parent: &Polymorphic{}}
+	comment.Parent.Set(&Page{Id: 3})
+	if comment.Parent.Type != "page" || comment.Parent.Value != int64(3) {
+		t.Error("Polymorphic Set Incorrect")
+	}
+
+	comments.Insert(comment).Exec(connection)
+	data = <-dataChan
+	if data.Parameters["parent_type"] != "page" || data.Parameters["parent_id"] != int64(3) {
+		t.Error("Inserting Polymorphic Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	stmt, _ := comments.Get().Compile(NewCompileContext())
+	if stmt != `SELECT "id", "body", COALESCE("parent_type", '') AS "parent.type", "parent_id" AS "parent.id" FROM comment` {
+		t.Error("Selecting Polymorphic Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	stmt, obj := comment.Parent.Get().Compile(NewCompileContext())
+	if stmt != `SELECT "id", "title" FROM page WHERE ("id" = :variable_id)` || obj["variable_id"] != int64(3) {
+		t.Error("Polymorphic Parent Incorrect SQL")
+	}
+	fmt.Println(stmt, obj)
+
+	Register("wiki", &Wiki{})
+	wikiParent := &Polymorphic{}
+	if wikiParent.Set(&Wiki{Slug: "home"}) != nil {
+		t.Error("Polymorphic Set Failed")
+	}
+	if (&Polymorphic{}).Set(&Stray{}) == nil {
+		t.Error("Polymorphic Set To Unregistered Model")
+	}
+	stmt, obj = wikiParent.Get().Compile(NewCompileContext())
+	if stmt != `SELECT "slug" FROM wiki WHERE ("slug" = :variable_slug)` || obj["variable_slug"] != "home" {
+		t.Error("Polymorphic String Keyed Parent Incorrect SQL")
+	}
+	fmt.Println(stmt, obj)
+
+	page := &Page{Id: 3}
+	loadRelationships(page, nil)
+	stmt, obj = page.Comments.Compile(NewCompileContext())
+	if stmt != `SELECT "id", "body", COALESCE("parent_type", '') AS "parent.type", "parent_id" AS "parent.id" FROM comment WHERE ("parent_type" = :variable_parent_type AND "parent_id" = :variable_parent_id)` ||
+		obj["variable_parent_type"] != "page" || obj["variable_parent_id"] != int64(3) {
+		t.Error("Polymorphic Reverse Incorrect SQL")
+	}
+	fmt.Println(stmt, obj)
+}
+
+func TestKeys(t *testing.T) {
+	u, err := ParseUUID("6ba7b810-9dad-41d1-80b4-00c04fd430c8")
+	if err != nil || u.String() != "6ba7b810-9dad-41d1-80b4-00c04fd430c8" {
+		t.Error("UUID Not Parsed")
+	}
+	if _, err := ParseUUID("6ba7b810"); err == nil {
+		t.Error("Invalid UUID Parsed")
+	}
+	scanned := UUID{}
+	if scanned.Scan([]byte(u.String())) != nil || scanned != u {
+		t.Error("UUID Not Scanned")
+	}
+	if generated := NewUUID(); generated.IsZero() || generated[6]>>4 != 4 {
+		t.Error("UUID Not Generated")
+	}
+
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+
+	accounts, _ := CreateTableFromStruct("account", connection, false, &Account{})
+	data := <-dataChan
+	if data.Statement != `CREATE TABLE IF NOT EXISTS account ("id" text, "name" text, CONSTRAINT account_pk PRIMARY KEY (id))` {
+		t.Error("Creating UUID Table Incorrect SQL")
+	}
+	fmt.Println(data.Statement)
+
+	CreateTableFromStruct("member", connection, false, &Member{})
+	data = <-dataChan
+	if data.Statement != `CREATE TABLE IF NOT EXISTS member ("id" text, "account" text, CONSTRAINT member_pk PRIMARY KEY (id), FOREIGN KEY (account) REFERENCES account(id))` {
+		t.Error("Creating String Key Table Incorrect SQL")
+	}
+	fmt.Println(data.Statement)
+
+	account := &Account{Name: "Acme"}
+	accounts.Insert(account).Exec(connection)
+	data = <-dataChan
+	if account.Id.IsZero() || data.Parameters["id"] != UUID(account.Id) || account.Members == nil {
+		t.Error("Inserting UUID Key Incorrect")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	stmt, obj := account.Members.Compile(NewCompileContext())
+	if obj["variable_account"] != UUID(account.Id) {
+		t.Error("UUID HasMany Incorrect SQL")
+	}
+	fmt.Println(stmt, obj)
+
+	member := &Member{Account: ForeignKey(account)}
+	if member.Account.Value != UUID(account.Id) {
+		t.Error("UUID ForeignKey Incorrect")
+	}
+
+	h := &HasOne{}
+	for _, v := range []interface{}{int64(1) << 40, "slug", []byte("slug"), nil} {
+		if h.Scan(v) != nil {
+			t.Error("HasOne Not Scanned")
+		}
+	}
+	if h.Scan(1.5) == nil {
+		t.Error("HasOne Scanned Float")
+	}
+}
+
+func TestCompositeKeys(t *testing.T) {
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+
+	readings, err := CreateTableFromStruct("reading", connection, false, &Reading{})
+	if err != nil {
+		t.Error(err)
+	}
+	data := <-dataChan
+	if data.Statement != `CREATE TABLE IF NOT EXISTS reading ("series" text, "ts" integer, "value" real, CONSTRAINT reading_pk PRIMARY KEY (series, ts))` {
+		t.Error("Creating Composite Key Table Incorrect SQL")
+	}
+	fmt.Println(data.Statement)
+
+	reading := &Reading{Series: "cpu", Ts: 1700000000, Value: 0.5}
+	stmt, obj := readings.Update(reading).Compile(NewCompileContext())
+	if stmt != `UPDATE reading SET "value" = :variable_value WHERE "series" = :variable_series AND "ts" = :variable_ts` ||
+		obj["variable_series"] != "cpu" || obj["variable_ts"] != int64(1700000000) {
+		t.Error("Updating Composite Key Incorrect SQL")
+	}
+	fmt.Println(stmt, obj)
+
+	stmt, _ = readings.Delete(reading).Compile(NewCompileContext())
+	if stmt != `DELETE FROM reading WHERE "series" = :variable_series AND "ts" = :variable_ts` {
+		t.Error("Deleting Composite Key Incorrect SQL")
+	}
+	fmt.Println(stmt)
+
+	readings.GetByKey(&Reading{}, "cpu", 1700000000)
+	data = <-dataChan
+	if data.Statement != `SELECT "series", "ts", "value" FROM reading WHERE ("series" = :variable_series AND "ts" = :variable_ts) LIMIT 1` {
+		t.Error("Getting Composite Key Incorrect SQL")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+
+	if readings.GetByKey(&Reading{}, "cpu") == nil {
+		t.Error("Partial Key Accepted")
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+
+	Register("story", &Story{})
+	_, err := CreateTableFromStruct("note", connection, false, &Note{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := <-dataChan
+	if data.Statement != `CREATE TABLE IF NOT EXISTS note ("id" integer, "story" integer, CONSTRAINT note_pk PRIMARY KEY (id), FOREIGN KEY (story) REFERENCES story(id) ON DELETE CASCADE ON UPDATE SET NULL)` {
+		t.Error("Creating Foreign Key Incorrect SQL")
+	}
+	fmt.Println(data.Statement)
+
+	if _, err := CreateTableFromStruct("bad_note", connection, false, &BadNote{}); err == nil {
+		t.Error("Unknown Referential Action Accepted")
+	}
+	if _, err := CreateTableFromStruct("stray", connection, false, &Stray{}); err == nil {
+		t.Error("Reference To Unregistered Table Accepted")
+	}
+
+	err = EnableForeignKeys(connection)
+	if err != nil {
+		t.Error(err)
+	}
+	if data = <-dataChan; data.Statement != "PRAGMA foreign_keys = ON" {
+		t.Error("Enabling Foreign Keys Incorrect SQL")
+	}
+}
+
+func TestUUIDColumns(t *testing.T) {
+	dataChan := make(chan Data, 1)
+	connection := &TestDb{
+		Data: dataChan,
+	}
+
+	devices, err := CreateTableFromStruct("device", connection, false, &Device{})
+	if err != nil {
+		t.Error(err)
+	}
+	data := <-dataChan
+	if data.Statement != `CREATE TABLE IF NOT EXISTS device ("id" integer, "ext" text, CONSTRAINT device_pk PRIMARY KEY (id))` {
+		t.Error("Creating UUID Column Incorrect SQL")
+	}
+	fmt.Println(data.Statement)
+
+	device := &Device{Ext: NewUUID()}
+	devices.Insert(device).Exec(connection)
+	data = <-dataChan
+	if data.Parameters["ext"] != device.Ext || device.Id != -5 {
+		t.Error("Inserting UUID Column Incorrect")
+	}
+	fmt.Println(data.Statement, data.Parameters)
+}
+
+func TestCompositeKeysSQLite(t *testing.T) {
+	conn := openSQLite(t)
+	defer conn.Close()
+
+	if _, err := CreateTableFromStruct("tenant_item", conn, true, &TenantItem{}); err == nil {
+		t.Error("PrimaryKey Accepted In Composite Key")
+	}
+
+	keyless, err := CreateTableFromStruct("keyless", conn, true, &Keyless{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyless.Update(&Keyless{Name: "a"}).Exec(conn); err == nil || !strings.Contains(err.Error(), "without a key") {
+		t.Error("Updating Without A Key Accepted")
+	}
+	if _, err := keyless.Delete(&Keyless{Name: "a"}).Exec(conn); err == nil || !strings.Contains(err.Error(), "without a key") {
+		t.Error("Deleting Without A Key Accepted")
+	}
+
+	readings, err := CreateTableFromStruct("reading", conn, true, &Reading{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range []float64{0.1, 0.2} {
+		_, err = readings.Insert(&Reading{Series: "cpu", Ts: int64(i), Value: v}).Exec(conn)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	reading := &Reading{}
+	err = readings.GetByKey(reading, "cpu", 1)
+	if err != nil || reading.Value != 0.2 {
+		t.Error("Getting Composite Key Failed")
+	}
+
+	reading.Value = 0.9
+	result, err := readings.Update(reading).Exec(conn)
+	if err != nil {
+		t.Error(err)
+	} else if n, _ := result.RowsAffected(); n != 1 {
+		t.Error("Updating Composite Key Missed Row")
+	}
+
+	result, err = readings.Delete(&Reading{Series: "cpu", Ts: 0}).Exec(conn)
+	if err != nil {
+		t.Error(err)
+	} else if n, _ := result.RowsAffected(); n != 1 {
+		t.Error("Deleting Composite Key Missed Row")
+	}
+
+	left := []Reading{}
+	err = readings.Get().All(conn, &left)
+	if err != nil || len(left) != 1 || left[0].Value != 0.9 {
+		t.Error("Composite Key Rows Incorrect")
+	}
+}
+
+func TestMissingHasOneSQLite(t *testing.T) {
+	conn := openSQLite(t)
+	defer conn.Close()
+
+	err := EnableForeignKeys(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateTableFromStruct("author", conn, true, &Author{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stories, err := CreateTableFromStruct("story", conn, true, &Story{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	story := &Story{Name: "Orphan"}
+	_, err = stories.Insert(story).Exec(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	story.Body = "Still no author."
+	_, err = stories.Update(story).Exec(conn)
+	if err != nil {
+		t.Error("Updating Missing HasOne Failed", err)
+	}
+
+	loaded := &Story{}
+	err = stories.Get().Where("id", int64(story.Id)).One(conn, loaded)
+	if err != nil || loaded.Author == nil || loaded.Author.Value != nil {
+		t.Error("Missing HasOne Not NULL")
+	}
+
+	// A model built by hand has no relationships loaded at all.
+	_, err = stories.Update(&Story{Id: story.Id, Name: "Rewritten"}).Exec(conn)
+	if err != nil {
+		t.Error("Updating Nil HasOne Failed", err)
+	}
+}
+
+func TestPolymorphicSQLite(t *testing.T) {
+	conn := openSQLite(t)
+	defer conn.Close()
+
+	pages, err := CreateTableFromStruct("page", conn, true, &Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments, err := CreateTableFromStruct("comment", conn, true, &Comment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"Home", "About"} {
+		_, err = pages.Insert(&Page{Title: v}).Exec(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range []string{"First!", "Second!"} {
+		comment := &Comment{Body: v, Parent: &Polymorphic{}}
+		comment.Parent.Set(&Page{Id: 1})
+		_, err = comments.Insert(comment).Exec(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	comment := &Comment{}
+	err = comments.Get().One(conn, comment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := &Page{}
+	err = comment.Parent.One(conn, page)
+	if err != nil || page.Title != "Home" {
+		t.Error("Loading Polymorphic Parent Failed")
+	}
+
+	loaded := []Page{}
+	err = pages.Get().Order("id", true).Preload("Comments").All(conn, &loaded)
+	if err != nil || len(loaded) != 2 {
+		t.Fatal("Preloading Polymorphic Children Failed", err)
+	}
+	home, _ := LoadedMany[Comment](loaded[0].Comments)
+	about, ok := LoadedMany[Comment](loaded[1].Comments)
+	if len(home) != 2 || len(about) != 0 || !ok {
+		t.Error("Preloaded Polymorphic Children Incorrect")
+	}
+
+	// Rows written without a parent point nowhere.
+	_, err = conn.Exec("INSERT INTO comment (body) VALUES ('Orphaned')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := []Comment{}
+	err = comments.Get().Order("id", true).All(conn, &all)
+	if err != nil || len(all) != 3 || all[2].Parent == nil || all[2].Parent.Type != "" || all[2].Parent.Value != nil {
+		t.Error("Polymorphic Without Parent Incorrect", err)
+	}
+}
+
+// storiesSQLite creates the author and story tables on conn, with two authors
+// and five stories alternating between them.
+func storiesSQLite(t *testing.T, conn *sqlx.DB) *BasicTable {
+	authors, err := CreateTableFromStruct("author", conn, true, &Author{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stories, err := CreateTableFromStruct("story", conn, true, &Story{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"Hunter", "Thompson"} {
+		_, err = authors.Insert(&Author{Name: v}).Exec(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		_, err = stories.Insert(&Story{
+			Name:   fmt.Sprint("story ", i),
+			Author: &HasOne{Value: int64(i%2 + 1)},
+		}).Exec(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stories
+}
+
+func TestEachSQLite(t *testing.T) {
+	conn := openSQLite(t)
+	defer conn.Close()
+	stories := storiesSQLite(t, conn)
+
+	seen := 0
+	err := stories.Get().Order("id", true).Each(conn, func(s *Story) error {
+		if s.Author == nil || s.Author.Value != int64(seen%2+1) {
+			t.Error("Each Relationships Not Loaded")
+		}
+		seen++
+		if seen == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	if err != nil || seen != 2 {
+		t.Error("Each Did Not Stop Early")
+	}
+	if conn.Stats().InUse != 0 {
+		t.Fatal("Each Left Rows Open")
+	}
+
+	failure := errors.New("export failed")
+	err = stories.Get().Each(conn, func(s *Story) error {
+		return failure
+	})
+	if err != failure || conn.Stats().InUse != 0 {
+		t.Error("Each Callback Error Incorrect")
+	}
+
+	var first *Story
+	for story, err := range Iterate[Story](conn, stories.Get().Order("id", true)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		first = story
+		break
+	}
+	if first == nil || conn.Stats().InUse != 0 {
+		t.Fatal("Iterate Left Rows Open")
+	}
+
+	// The connection is free again, so the relationship can be followed.
+	author := &Author{}
+	if first.Author == nil || first.Author.One(conn, author) != nil || author.Name != "Hunter" {
+		t.Error("Iterate Relationships Not Loaded")
+	}
+}
+
+func TestAllRelationshipsSQLite(t *testing.T) {
+	conn := openSQLite(t)
+	defer conn.Close()
+	stories := storiesSQLite(t, conn)
+
+	values := []Story{}
+	err := stories.Get().Order("id", true).All(conn, &values)
+	if err != nil || len(values) != 5 {
+		t.Fatal("Selecting Stories Failed", err)
+	}
+	for i, v := range values {
+		author := &Author{}
+		if v.Author == nil || v.Author.One(conn, author) != nil || author.Id != PrimaryKey(i%2+1) {
+			t.Error("All Relationships Not Loaded")
+		}
+	}
+
+	pointers := []*Story{}
+	err = stories.Get().Order("id", true).All(conn, &pointers)
+	if err != nil || len(pointers) != 5 {
+		t.Fatal("Selecting Stories Failed", err)
+	}
+	for i, v := range pointers {
+		author := &Author{}
+		if v.Author == nil || v.Author.One(conn, author) != nil || author.Id != PrimaryKey(i%2+1) {
+			t.Error("All Pointer Relationships Not Loaded")
+		}
+	}
+
+	author := &Author{}
+	err = pointers[0].Author.One(conn, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := []Story{}
+	err = author.Stories.All(conn, &written)
+	if err != nil || len(written) != 3 {
+		t.Error("All Reverse Relationship Incorrect")
+	}
+}
+
+func TestPreloadSQLite(t *testing.T) {
+	conn := openSQLite(t)
+	defer conn.Close()
+	stories := storiesSQLite(t, conn)
+
+	loaded := []*Story{}
+	err := stories.Get().Order("id", true).Preload("Author", "Author.Stories").All(conn, &loaded)
+	if err != nil || len(loaded) != 5 {
+		t.Fatal("Preloading Stories Failed", err)
+	}
+
+	for i, v := range loaded {
+		author, ok := LoadedOne[Author](v.Author)
+		if !ok || author == nil || author.Id != PrimaryKey(i%2+1) {
+			t.Fatal("Preloaded Author Incorrect")
+		}
+		written, ok := LoadedMany[Story](author.Stories)
+		if !ok || len(written) != 3-i%2 {
+			t.Error("Preloaded Author Stories Incorrect")
+		}
+	}
+
+	// Stories sharing an author share the loaded model.
+	first, _ := LoadedOne[Author](loaded[0].Author)
+	third, _ := LoadedOne[Author](loaded[2].Author)
+	if first != third {
+		t.Error("Preloaded Author Not Shared")
+	}
+
+	// Preloading selects the mapped columns, so added columns are ignored.
+	_, err = conn.Exec("ALTER TABLE author ADD COLUMN bio text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded = []*Story{}
+	err = stories.Get().Preload("Author").All(conn, &loaded)
+	if author, _ := LoadedOne[Author](loaded[0].Author); err != nil || author == nil {
+		t.Error("Preload Broken By Added Column", err)
+	}
+
+	err = stories.Get().Preload("Author").Each(conn, func(s *Story) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("Each Ignored Preload")
+	}
+	for _, err := range Iterate[Story](conn, stories.Get().Preload("Author")) {
+		if err == nil {
+			t.Error("Iterate Ignored Preload")
+		}
+	}
+	err = stories.Get().Preload("Author").Union(stories.Get()).All(conn, &loaded)
+	if err == nil {
+		t.Error("Compound Query Ignored Preload")
+	}
+	if conn.Stats().InUse != 0 {
+		t.Error("Rejected Preload Left Rows Open")
+	}
+}
+
+func TestPaginateSQLite(t *testing.T) {
+	conn := openSQLite(t)
+	defer conn.Close()
+
+	_, err := conn.Exec("CREATE TABLE entry (id integer PRIMARY KEY, date timestamp, name text)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Register("entry", &Entry{})
+	start := time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		// Pairs of entries share a date, so the id breaks ties.
+		_, err = conn.Exec("INSERT INTO entry (date, name) VALUES (?, ?)", start.Add(time.Duration(i/2)*time.Hour), fmt.Sprint("entry ", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := make([]string, 0, 6)
+	var cursor Cursor
+	for pages := 0; pages < 6; pages++ {
+		// Pass the cursor through its token, as a client would.
+		cursor, err = ParseCursor(cursor.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page := []Entry{}
+		cursor, err = (&SelectStatement{
+			Table: "entry",
+		}).Order("date", false).Order("id", false).Paginate(conn, 2, cursor, &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range page {
+			names = append(names, v.Name)
+		}
+		if cursor == nil {
+			break
+		}
+	}
+
+	if strings.Join(names, ",") != "entry 5,entry 4,entry 3,entry 2,entry 1,entry 0" {
+		t.Error("Paginating By Date Incorrect", names)
+	}
+}
+
+func TestAddedColumnSQLite(t *testing.T) {
+	conn := openSQLite(t)
+	defer conn.Close()
+	stories := storiesSQLite(t, conn)
+
+	_, err := conn.Exec("ALTER TABLE author ADD COLUMN bio text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	story := &Story{}
+	err = stories.Get().Order("id", true).One(conn, story)
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := &Author{}
+	err = story.Author.One(conn, author)
+	if err != nil || author.Name != "Hunter" {
+		t.Error("Relationship Broken By Added Column", err)
+	}
+
+	written := []Story{}
+	err = author.Stories.All(conn, &written)
+	if err != nil || len(written) != 3 {
+		t.Error("Reverse Relationship Broken By Added Column", err)
+	}
+}
@@ -1,7 +1,7 @@
 package db
 
 import (
-	"errors"
+	"fmt"
 	"reflect"
 )
 
@@ -13,50 +13,249 @@ import (
 
 type HasMany struct {
 	*SelectStatement
+	// Preloaded
+	loaded    interface{}
+	preloaded bool
+}
+
+// Loaded returns the models attached by Preload, a []*T, and whether the
+// relationship was preloaded.
+func (h *HasMany) Loaded() (interface{}, bool) {
+	return h.loaded, h.preloaded
 }
 
 type HasOne struct {
 	*SelectStatement
-	Value int
+	Value interface{}
 	// Internal
 	column string
+	// Preloaded
+	loaded    interface{}
+	preloaded bool
+}
+
+// Loaded returns the model attached by Preload, a *T that is nil when no row
+// matched, and whether the relationship was preloaded.
+func (h *HasOne) Loaded() (interface{}, bool) {
+	return h.loaded, h.preloaded
 }
 
+// Scan reads the key of the related row, an integer, text or NULL.
 func (h *HasOne) Scan(src interface{}) error {
-	i, ok := src.(int64)
-	if !ok {
-		return errors.New("Cannot scan non-integer into HasOne field.")
+	switch v := src.(type) {
+	case int64, string, nil:
+		h.Value = v
+	case []byte:
+		h.Value = string(v)
+	default:
+		return fmt.Errorf("Cannot scan %T into HasOne field.", src)
 	}
-
-	h.Value = int(i)
 	return nil
 }
 
 func ForeignKey(obj interface{}) *HasOne {
-	id := -1
-	examineObject(obj,
-		func(p PrimaryKey, n string) {
-			id = int(p)
-		}, nil, nil, nil)
 	return &HasOne{
-		Value: id,
+		Value: keyOf(obj),
 	}
 }
 
 func (f *HasOne) Set(obj interface{}) {
-	id := -1
-	examineObject(obj,
-		func(p PrimaryKey, n string) {
-			id = int(p)
-		}, nil, nil, nil)
+	id := keyOf(obj)
 	f.Value = id
-	f.SelectStatement.WhereClause = nil
-	f.SelectStatement = f.SelectStatement.Where(f.column, id)
+	f.loaded, f.preloaded = nil, false
+
+	// Replace rather than modify the statement, which others may hold.
+	if f.SelectStatement != nil {
+		f.SelectStatement = selectFrom(f.SelectStatement.Table).Where(f.column, id)
+	}
+}
+
+// ManyToMany relates models through a link table holding a pair of keys:
+//
+//	Tags *db.ManyToMany `table:"tag" through:"story_tag" key:"story" foreign:"tag"`
+//
+// key defaults to the model's name, foreign to the related table and through
+// to "key_foreign". The embedded query selects the related rows.
+type ManyToMany struct {
+	*SelectStatement
+	// Internal
+	id      interface{}
+	through string
+	key     string
+	foreign string
+}
+
+// newManyToMany reads the link table of field, on a model of type owner,
+// and selects the rows related to id.
+func newManyToMany(owner reflect.Type, field reflect.StructField, id interface{}) *ManyToMany {
+	table := toSnakeCase(field.Tag.Get("table"))
+	column := field.Tag.Get("on")
+	if column == "" {
+		column = "id"
+	}
+
+	m := &ManyToMany{
+		id:      id,
+		through: field.Tag.Get("through"),
+		key:     field.Tag.Get("key"),
+		foreign: field.Tag.Get("foreign"),
+	}
+	if m.key == "" {
+		m.key = toSnakeCase(owner.Name())
+	}
+	if m.foreign == "" {
+		m.foreign = table
+	}
+	if m.through == "" {
+		m.through = m.key + "_" + m.foreign
+	}
+
+	query := selectFrom(table)
+	if len(query.Projection) == 0 {
+		query = query.Columns(table + ".*")
+	} else {
+		model, _ := registeredModel(table)
+		query = query.Select(modelColumns(model, table)...)
+	}
+	m.SelectStatement = query.Join(m.through, "", On(m.through+"."+m.foreign, table+"."+toSnakeCase(column))).Where(m.through+"."+m.key, id)
+	return m
 }
 
-type PrimaryKey int
+// Add links objects to the model.
+func (m *ManyToMany) Add(db Executor, objects ...interface{}) error {
+	for _, v := range objects {
+		_, err := execute(db, &InsertStatement{
+			Table: m.through,
+			Values: map[string]interface{}{
+				m.key:     m.id,
+				m.foreign: keyOf(v),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove unlinks objects from the model.
+func (m *ManyToMany) Remove(db Executor, objects ...interface{}) error {
+	keys := make([]interface{}, len(objects))
+	for i, v := range objects {
+		keys[i] = keyOf(v)
+	}
+
+	_, err := execute(db, &DeleteStatement{
+		Table: m.through,
+		Where: AndClauses{
+			&NamedEquality{
+				Name:  m.key,
+				Value: m.id,
+			},
+			&In{
+				Name:   m.foreign,
+				Values: keys,
+			},
+		},
+	})
+	return err
+}
+
+// Clear unlinks every object from the model.
+func (m *ManyToMany) Clear(db Executor) error {
+	_, err := execute(db, &DeleteStatement{
+		Table: m.through,
+		Where: &NamedEquality{
+			Name:  m.key,
+			Value: m.id,
+		},
+	})
+	return err
+}
+
+// Replace links exactly objects to the model. Run it in a transaction to
+// keep the change atomic.
+func (m *ManyToMany) Replace(db Executor, objects ...interface{}) error {
+	err := m.Clear(db)
+	if err != nil {
+		return err
+	}
+	return m.Add(db, objects...)
+}
+
+// Polymorphic points at a row of any registered table, stored as the table
+// name in a <field>_type column and its key, as text, in <field>_id so that
+// any kind of key fits:
+//
+//	Parent *db.Polymorphic
+//
+// Models reach the rows pointing at them with a HasMany tagged `as:"parent"`.
+// A row whose <field>_type is NULL points nowhere, with an empty Type.
+type Polymorphic struct {
+	Type  string
+	Value interface{} `db:"id"`
+}
+
+// Set points the field at obj, whose model must be registered.
+func (p *Polymorphic) Set(obj interface{}) error {
+	table, ok := registeredName(reflect.TypeOf(obj))
+	if !ok {
+		return fmt.Errorf("Can't point a Polymorphic at unregistered model %T.", obj)
+	}
+
+	p.Type = table
+	p.Value = keyOf(obj)
+	return nil
+}
+
+// Get selects the row the field points at, by the key of the model
+// registered for its table.
+func (p *Polymorphic) Get() *SelectStatement {
+	column := "id"
+	if model, ok := registeredModel(p.Type); ok {
+		if keys := keyColumns(model); len(keys) > 0 {
+			column = keys[0]
+		}
+	}
+	return selectFrom(p.Type).Where(column, p.Value)
+}
+
+// One loads the row the field points at into object, which must be of the
+// model registered for its table.
+func (p *Polymorphic) One(db Executor, object interface{}) error {
+	return p.Get().One(db, object)
+}
+
+// Load loads the row the field points at into a new model of the registered
+// type, returned as a pointer.
+func (p *Polymorphic) Load(db Executor) (interface{}, error) {
+	model, ok := registeredModel(p.Type)
+	if !ok {
+		return nil, fmt.Errorf("Table %s is not registered.", p.Type)
+	}
+
+	object := reflect.New(model).Interface()
+	err := p.One(db, object)
+	if err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// PrimaryKey is an auto-incrementing integer key, assigned by the database on
+// insert.
+type PrimaryKey int64
+
+// StringKey is a text key, such as a slug or ULID. An empty key is given a
+// random UUID on insert.
+type StringKey string
 
 var primaryKeyType = reflect.TypeOf(PrimaryKey(0))
+var stringKeyType = reflect.TypeOf(StringKey(""))
+var uuidType = reflect.TypeOf(UUID{})
+var uuidKeyType = reflect.TypeOf(UUIDKey{})
 
 var hasOneType = reflect.TypeOf((*HasOne)(nil))
 var hasManyType = reflect.TypeOf((*HasMany)(nil))
+var manyToManyType = reflect.TypeOf((*ManyToMany)(nil))
+var polymorphicType = reflect.TypeOf((*Polymorphic)(nil))
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"errors"
+	"iter"
+	"reflect"
+)
+
+// ErrStop ends an Each iteration early when returned by its callback, without
+// Each returning an error.
+var ErrStop = errors.New("Stop iteration.")
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Each scans the rows of the query one at a time into a new model and calls
+// fn, a func(*T) error, with it once its relationships are loaded. Any error
+// from fn other than ErrStop ends the iteration and is returned. Queries with
+// preloads are rejected, since rows are handed out before they could load.
+func (q *SelectStatement) Each(db Executor, fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func || f.Type().NumIn() != 1 || f.Type().In(0).Kind() != reflect.Ptr ||
+		f.Type().NumOut() != 1 || f.Type().Out(0) != errorType {
+		return errors.New("Each requires a func(*T) error.")
+	}
+
+	model := f.Type().In(0).Elem()
+	return eachRow(db, q, func() interface{} {
+		return reflect.New(model).Interface()
+	}, func(object interface{}) error {
+		err, _ := f.Call([]reflect.Value{reflect.ValueOf(object)})[0].Interface().(error)
+		return err
+	})
+}
+
+// Iterate scans the rows of query one at a time, for use with range:
+//
+//	for story, err := range db.Iterate[Story](conn, stories.Get()) {
+//
+// Breaking out of the loop closes the rows. As with Each, queries with
+// preloads are rejected.
+func Iterate[T any](db Executor, query Clause) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		err := eachRow(db, query, func() interface{} {
+			return new(T)
+		}, func(object interface{}) error {
+			if !yield(object.(*T), nil) {
+				return ErrStop
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// eachRow scans every row of query into a model from newObject and hands it
+// to fn, until fn returns an error.
+func eachRow(db Executor, query Clause, newObject func() interface{}, fn func(interface{}) error) error {
+	err := rejectPreloads("Preload is not supported while iterating rows.", query)
+	if err != nil {
+		return err
+	}
+
+	stmt, obj, err := compileFor(db, query)
+	if err != nil {
+		return err
+	}
+	rows, err := db.NamedQuery(stmt, obj)
+	if err != nil {
+		return err
+	}
+	defer rows.Rows.Close()
+
+	for rows.Next() {
+		object := newObject()
+		err = rows.StructScan(object)
+		if err != nil {
+			return err
+		}
+
+		if reflect.TypeOf(object).Elem().Kind() == reflect.Struct {
+			loadRelationships(object, nil)
+		}
+
+		err = fn(object)
+		if err == ErrStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
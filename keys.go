@@ -0,0 +1,145 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+)
+
+// UUID is a 128-bit value, stored as text (uuid on postgres).
+type UUID [16]byte
+
+// UUIDKey is a UUID primary key, generated on insert when zero.
+type UUIDKey UUID
+
+// NewUUID generates a random (version 4) UUID.
+func NewUUID() UUID {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+// ParseUUID reads a UUID in its canonical, hyphenated text form.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("Invalid UUID %q.", s)
+	}
+
+	text := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(text)); err != nil {
+		return u, fmt.Errorf("Invalid UUID %q.", s)
+	}
+	return u, nil
+}
+
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+func (u UUID) String() string {
+	text := hex.EncodeToString(u[:])
+	return text[0:8] + "-" + text[8:12] + "-" + text[12:16] + "-" + text[16:20] + "-" + text[20:]
+}
+
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+func (u *UUID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		parsed, err := ParseUUID(v)
+		*u = parsed
+		return err
+	case []byte:
+		if len(v) == len(u) {
+			copy(u[:], v)
+			return nil
+		}
+		return u.Scan(string(v))
+	}
+	return fmt.Errorf("Cannot scan %T into UUID field.", src)
+}
+
+func (u UUIDKey) IsZero() bool {
+	return UUID(u).IsZero()
+}
+
+func (u UUIDKey) String() string {
+	return UUID(u).String()
+}
+
+func (u UUIDKey) Value() (driver.Value, error) {
+	return UUID(u).Value()
+}
+
+func (u *UUIDKey) Scan(src interface{}) error {
+	return (*UUID)(u).Scan(src)
+}
+
+// uuidColumnType is the column type of UUIDs.
+func uuidColumnType(db Database) string {
+	if db.DriverName() == "postgres" {
+		return "uuid"
+	}
+	return "text"
+}
+
+// keyValue unwraps a primary key field into the value bound for it: int64
+// for PrimaryKey, string for StringKey and UUID for UUIDKey.
+func keyValue(key interface{}) interface{} {
+	switch v := key.(type) {
+	case PrimaryKey:
+		return int64(v)
+	case StringKey:
+		return string(v)
+	case UUIDKey:
+		return UUID(v)
+	}
+	return key
+}
+
+// keyOf reads the key of object, a pointer to a model, or nil when it has
+// none.
+func keyOf(object interface{}) interface{} {
+	var key interface{}
+	examineObject(object, func(k interface{}, name string) {
+		key = k
+	}, nil, nil, nil, nil, nil)
+	return key
+}
+
+// generateKey fills in a zero client-side key of field, returning its value.
+// Auto-incrementing PrimaryKeys are left for the database to assign.
+func generateKey(field reflect.Value) interface{} {
+	switch v := field.Interface().(type) {
+	case UUIDKey:
+		if v.IsZero() {
+			field.Set(reflect.ValueOf(UUIDKey(NewUUID())))
+		}
+	case StringKey:
+		if v == "" {
+			field.SetString(NewUUID().String())
+		}
+	}
+	return keyValue(field.Interface())
+}
+
+// keyColumnType is the column type of the key of model, or of a column
+// referencing it when pk is false.
+func keyColumnType(db Database, model reflect.Type, pk bool) string {
+	switch keyOf(reflect.New(model).Interface()).(type) {
+	case UUID:
+		return uuidColumnType(db)
+	case string:
+		return ConvertKindToDB(db, reflect.String, false)
+	}
+	return ConvertKindToDB(db, reflect.Int64, pk)
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// A Cursor marks a position in an ordered query as the values of the
+// ordering keys of the last row seen.
+type Cursor []interface{}
+
+// String encodes the cursor into an opaque, URL safe token. Times and byte
+// slices are tagged with their type, as {"time": ...} or {"bytes": ...}, so
+// that ParseCursor restores them rather than leaving text.
+func (c Cursor) String() string {
+	if c == nil {
+		return ""
+	}
+
+	values := make([]interface{}, len(c))
+	for i, v := range c {
+		switch v := v.(type) {
+		case time.Time:
+			values[i] = map[string]string{"time": v.Format(time.RFC3339Nano)}
+		case []byte:
+			values[i] = map[string]string{"bytes": base64.StdEncoding.EncodeToString(v)}
+		default:
+			values[i] = v
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a token produced by Cursor.String. The empty token is
+// the nil cursor, which starts at the first page.
+func ParseCursor(token string) (Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("Invalid cursor.")
+	}
+
+	values := make([]interface{}, 0)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, errors.New("Invalid cursor.")
+	}
+
+	// Restore numbers, times and bytes as the types database drivers expect.
+	for i, v := range values {
+		switch v := v.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				values[i] = n
+			} else if f, err := v.Float64(); err == nil {
+				values[i] = f
+			}
+		case map[string]interface{}:
+			value, err := parseCursorValue(v)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+	}
+
+	return Cursor(values), nil
+}
+
+// parseCursorValue restores a value tagged with its type by Cursor.String.
+func parseCursorValue(tagged map[string]interface{}) (interface{}, error) {
+	if text, ok := tagged["time"].(string); ok && len(tagged) == 1 {
+		if t, err := time.Parse(time.RFC3339Nano, text); err == nil {
+			return t, nil
+		}
+	}
+	if text, ok := tagged["bytes"].(string); ok && len(tagged) == 1 {
+		if b, err := base64.StdEncoding.DecodeString(text); err == nil {
+			return b, nil
+		}
+	}
+	return nil, errors.New("Invalid cursor.")
+}
+
+// KeysetClause matches the rows that come strictly after Values when ordered
+// by Keys.
+type KeysetClause struct {
+	Keys   []OrderClause
+	Values []interface{}
+}
+
+func (c *KeysetClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	if len(c.Keys) != len(c.Values) {
+		ctx.Fail(errors.New("Cursor does not match the ordering keys."))
+		return "1 = 0", nil
+	}
+	// Comparisons with NULL are never true, so those rows can't be sought past.
+	for _, v := range c.Keys {
+		if v.Nulls != NullsDefault {
+			ctx.Fail(fmt.Errorf("Cannot seek past %s, which orders NULLs explicitly.", v.Key))
+			return "1 = 0", nil
+		}
+	}
+
+	object := make(map[string]interface{})
+	names := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		names[i] = ctx.Name("variable_cursor")
+		object[names[i]] = v
+	}
+
+	// (a, b) > (:a, :b) when every key runs the same direction.
+	sameDirection := true
+	for _, v := range c.Keys {
+		sameDirection = sameDirection && v.Ascending == c.Keys[0].Ascending
+	}
+	if sameDirection && len(c.Keys) > 1 {
+		keys := make([]string, len(c.Keys))
+		params := make([]string, len(c.Keys))
+		for i, v := range c.Keys {
+			keys[i] = v.Key
+			params[i] = ":" + names[i]
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(keys, sqlComma), keysetOperator(c.Keys[0]), strings.Join(params, sqlComma)), object
+	}
+
+	// a > :a OR (a = :a AND b < :b) ...
+	alternatives := make([]string, len(c.Keys))
+	for i, v := range c.Keys {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = :%s", c.Keys[j].Key, names[j]))
+		}
+		terms = append(terms, fmt.Sprintf("%s %s :%s", v.Key, keysetOperator(v), names[i]))
+		alternatives[i] = strings.Join(terms, sqlAnd)
+		if len(terms) > 1 && len(c.Keys) > 1 {
+			alternatives[i] = "(" + alternatives[i] + ")"
+		}
+	}
+	return strings.Join(alternatives, sqlOr), object
+}
+
+func keysetOperator(c OrderClause) string {
+	if c.Ascending {
+		return ">"
+	}
+	return "<"
+}
+
+// After restricts the query to the rows following cursor in the current
+// ordering, so Order must be called first. A nil cursor changes nothing.
+func (q *SelectStatement) After(cursor Cursor) *SelectStatement {
+	if cursor == nil {
+		return q
+	}
+	return q.WhereClauseAnd(&KeysetClause{
+		Keys:   append([]OrderClause(nil), q.OrderClauses...),
+		Values: cursor,
+	})
+}
+
+// Paginate loads up to limit rows following cursor into object, a pointer to
+// a slice, and returns the cursor of the next page (nil on the last page).
+// Orderings that place NULLs explicitly can't be paginated.
+func (q *SelectStatement) Paginate(db Executor, limit int, cursor Cursor, object interface{}) (Cursor, error) {
+	if len(q.OrderClauses) == 0 {
+		return nil, errors.New("Cannot paginate a query without an ordering.")
+	}
+	if limit <= 0 {
+		return nil, errors.New("Cannot paginate with a limit below one.")
+	}
+	for _, v := range q.OrderClauses {
+		if v.Nulls != NullsDefault {
+			return nil, fmt.Errorf("Cannot paginate on %s, which orders NULLs explicitly.", v.Key)
+		}
+	}
+	if cursor != nil && len(cursor) != len(q.OrderClauses) {
+		return nil, errors.New("Cursor does not match the ordering of the query.")
+	}
+
+	// One more row than requested tells us whether there is a next page.
+	err := q.After(cursor).Limit(limit+1).All(db, object)
+	if err != nil {
+		return nil, err
+	}
+
+	results := reflect.ValueOf(object).Elem()
+	if results.Len() <= limit {
+		return nil, nil
+	}
+	results.Set(results.Slice(0, limit))
+
+	last := results.Index(limit - 1)
+	next := make(Cursor, len(q.OrderClauses))
+	for i, v := range q.OrderClauses {
+		value, ok := columnValue(last, ParseColumn(v.Key).Name)
+		if !ok {
+			return nil, fmt.Errorf("Cannot find ordering key %s in results.", v.Key)
+		}
+		next[i] = value
+	}
+	return next, nil
+}
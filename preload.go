@@ -0,0 +1,312 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Preload loads the named relationships of the models returned by One, All
+// and Paginate up front, with one IN query per relationship rather than one
+// per model. Dotted paths load nested relationships:
+//
+//	stories.Get().Preload("Author", "Author.Stories").All(conn, &list)
+//
+// The tables involved must be registered (CreateTableFromStruct does this),
+// and the loaded models are read with Loaded, LoadedOne or LoadedMany.
+func (q *SelectStatement) Preload(paths ...string) *SelectStatement {
+	q = q.Clone()
+	q.Preloads = append(q.Preloads, paths...)
+	return q
+}
+
+// fetcher runs a preload query into a pointer to a slice of models.
+type fetcher func(query *SelectStatement, object interface{}) error
+
+// preloadAll preloads paths on object, a pointer to a struct or to a slice of
+// structs or of pointers to structs.
+func preloadAll(db Executor, object interface{}, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	return preload(modelsOf(reflect.ValueOf(object)), paths, func(query *SelectStatement, object interface{}) error {
+		return queryAll(db, query, object)
+	})
+}
+
+// rejectPreloads fails with message when any of queries has preloads, for
+// the ways of running queries that can't preload rather than dropping them.
+func rejectPreloads(message string, queries ...Clause) error {
+	for _, v := range queries {
+		if q, ok := v.(*SelectStatement); ok && len(q.Preloads) > 0 {
+			return errors.New(message)
+		}
+	}
+	return nil
+}
+
+// modelsOf lists the addressable structs held by object.
+func modelsOf(object reflect.Value) []reflect.Value {
+	object = object.Elem()
+	if object.Kind() == reflect.Struct {
+		return []reflect.Value{object}
+	}
+
+	models := make([]reflect.Value, 0, object.Len())
+	for i := 0; i < object.Len(); i++ {
+		element := object.Index(i)
+		if element.Kind() == reflect.Ptr {
+			if element.IsNil() {
+				continue
+			}
+			element = element.Elem()
+		}
+		if element.Kind() == reflect.Struct {
+			models = append(models, element)
+		}
+	}
+	return models
+}
+
+func preload(models []reflect.Value, paths []string, fetch fetcher) error {
+	if len(models) == 0 {
+		return nil
+	}
+
+	// Group the paths by their first relationship, keeping their order.
+	names := make([]string, 0, len(paths))
+	nested := make(map[string][]string)
+	for _, v := range paths {
+		name, rest, _ := strings.Cut(v, ".")
+		if _, ok := nested[name]; !ok {
+			names = append(names, name)
+			nested[name] = nil
+		}
+		if rest != "" {
+			nested[name] = append(nested[name], rest)
+		}
+	}
+
+	for _, name := range names {
+		field, ok := models[0].Type().FieldByName(name)
+		if !ok {
+			return fmt.Errorf("Cannot preload unknown relationship %s.", name)
+		}
+
+		var related []reflect.Value
+		var err error
+		switch field.Type {
+		case hasOneType:
+			related, err = preloadOne(models, field, fetch)
+		case hasManyType:
+			related, err = preloadMany(models, field, fetch)
+		default:
+			return fmt.Errorf("Cannot preload %s, which is not a relationship.", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = preload(related, nested[name], fetch)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preloadOne loads the row each HasOne field points at, returning the rows
+// loaded.
+func preloadOne(models []reflect.Value, field reflect.StructField, fetch fetcher) ([]reflect.Value, error) {
+	column := field.Tag.Get("on")
+	if column == "" {
+		column = "id"
+	}
+	column = toSnakeCase(column)
+
+	keys := make([]interface{}, 0, len(models))
+	seen := make(map[interface{}]bool)
+	for _, v := range models {
+		h := v.FieldByIndex(field.Index).Interface().(*HasOne)
+		if h == nil {
+			continue
+		}
+		key := normalizeKey(h.Value)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	rows, err := fetchRelated(field, column, keys, nil, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[interface{}]reflect.Value)
+	for _, v := range rows {
+		key, _ := columnValue(v, column)
+		index[normalizeKey(key)] = v.Addr()
+	}
+
+	for _, v := range models {
+		h := v.FieldByIndex(field.Index).Interface().(*HasOne)
+		if h == nil {
+			continue
+		}
+		loaded, ok := index[normalizeKey(h.Value)]
+		if !ok {
+			loaded = reflect.Zero(reflect.PtrTo(rowsType(field)))
+		}
+		h.loaded = loaded.Interface()
+		h.preloaded = true
+	}
+	return rows, nil
+}
+
+// preloadMany loads the rows pointing back at each model with a HasMany
+// field, returning the rows loaded.
+func preloadMany(models []reflect.Value, field reflect.StructField, fetch fetcher) ([]reflect.Value, error) {
+	column := toSnakeCase(field.Tag.Get("on"))
+	normalize := normalizeKey
+	var where Clause
+	if as := toSnakeCase(field.Tag.Get("as")); as != "" {
+		// Rows pointing back through a Polymorphic field, whose keys are
+		// stored as text.
+		column = as + "_id"
+		where = &NamedEquality{
+			Name:  as + "_type",
+			Value: tableOf(models[0].Type()),
+		}
+		normalize = func(key interface{}) interface{} {
+			return fmt.Sprint(normalizeKey(key))
+		}
+	}
+	if column == "" {
+		return nil, fmt.Errorf("Cannot preload %s without an on column.", field.Name)
+	}
+
+	keys := make([]interface{}, 0, len(models))
+	for _, v := range models {
+		if v.FieldByIndex(field.Index).IsNil() {
+			continue
+		}
+		keys = append(keys, keyOf(v.Addr().Interface()))
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	rows, err := fetchRelated(field, column, keys, where, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	sliceType := reflect.SliceOf(reflect.PtrTo(rowsType(field)))
+	groups := make(map[interface{}]reflect.Value)
+	for _, v := range rows {
+		key, _ := columnValue(v, column)
+		key = normalize(key)
+		group, ok := groups[key]
+		if !ok {
+			group = reflect.MakeSlice(sliceType, 0, 1)
+		}
+		groups[key] = reflect.Append(group, v.Addr())
+	}
+
+	for _, v := range models {
+		h := v.FieldByIndex(field.Index).Interface().(*HasMany)
+		if h == nil {
+			continue
+		}
+		group, ok := groups[normalize(keyOf(v.Addr().Interface()))]
+		if !ok {
+			group = reflect.MakeSlice(sliceType, 0, 0)
+		}
+		h.loaded = group.Interface()
+		h.preloaded = true
+	}
+	return rows, nil
+}
+
+// fetchRelated runs the batch query for a relationship field, selecting the
+// rows of its table whose column is among keys and that match where, if set.
+func fetchRelated(field reflect.StructField, column string, keys []interface{}, where Clause, fetch fetcher) ([]reflect.Value, error) {
+	table := toSnakeCase(field.Tag.Get("table"))
+	if _, ok := registeredModel(table); !ok {
+		return nil, fmt.Errorf("Cannot preload %s, table %s is not registered.", field.Name, table)
+	}
+
+	query := selectFrom(table).WhereIn(column, keys)
+	if where != nil {
+		query = query.WhereClauseAnd(where)
+	}
+
+	results := reflect.New(reflect.SliceOf(reflect.PtrTo(rowsType(field))))
+	err := fetch(query, results.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]reflect.Value, 0, results.Elem().Len())
+	for i := 0; i < results.Elem().Len(); i++ {
+		if v := results.Elem().Index(i); !v.IsNil() {
+			rows = append(rows, v.Elem())
+		}
+	}
+	return rows, nil
+}
+
+// rowsType is the registered model of the table a relationship points at.
+func rowsType(field reflect.StructField) reflect.Type {
+	model, _ := registeredModel(toSnakeCase(field.Tag.Get("table")))
+	return model
+}
+
+// normalizeKey makes keys read from different fields comparable, so that an
+// int foreign key matches an int64 primary key and a UUID its text.
+func normalizeKey(key interface{}) interface{} {
+	switch v := key.(type) {
+	case UUID:
+		return v.String()
+	case UUIDKey:
+		return v.String()
+	}
+
+	switch v := reflect.ValueOf(key); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Slice:
+		if b, ok := key.([]byte); ok {
+			return string(b)
+		}
+	}
+	return key
+}
+
+// LoadedOne returns the model preloaded into h, which is nil when no row
+// matched, and whether h was preloaded at all.
+func LoadedOne[T any](h *HasOne) (*T, bool) {
+	if h == nil || !h.preloaded {
+		return nil, false
+	}
+	loaded, ok := h.loaded.(*T)
+	return loaded, ok
+}
+
+// LoadedMany returns the models preloaded into h and whether h was preloaded
+// at all.
+func LoadedMany[T any](h *HasMany) ([]*T, bool) {
+	if h == nil || !h.preloaded {
+		return nil, false
+	}
+	loaded, ok := h.loaded.([]*T)
+	return loaded, ok
+}
@@ -3,79 +3,399 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"reflect"
 
 	"github.com/jmoiron/sqlx"
 )
 
-type Query interface {
-	One(db Executor, object interface{}) error
-	All(db Executor, object []interface{}) error
-}
-
 // A Simple SQL Select Statement
 type SelectStatement struct {
-	Table       string
-	WhereClause Clause
-	LimitClause Clause
-	OrderClause Clause
+	CommonTables   WithClause
+	Table          string
+	Source         Clause
+	TableAlias     string
+	DistinctClause Clause
+	Projection     []Clause
+	Joins          []JoinClause
+	WhereClause    Clause
+	GroupClauses   []Clause
+	HavingClause   Clause
+	LimitClause    Clause
+	OffsetClause   Clause
+	OrderClauses   []OrderClause
+	LockClause     *LockClause
+	Preloads       []string
+}
+
+// Clone copies the statement, so that changes to the copy leave the original
+// untouched. Every builder method clones its receiver, so a statement can be
+// shared and extended freely.
+func (q *SelectStatement) Clone() *SelectStatement {
+	out := *q
+	out.CommonTables = append(WithClause(nil), q.CommonTables...)
+	out.Projection = append([]Clause(nil), q.Projection...)
+	out.Joins = append([]JoinClause(nil), q.Joins...)
+	out.GroupClauses = append([]Clause(nil), q.GroupClauses...)
+	out.OrderClauses = append([]OrderClause(nil), q.OrderClauses...)
+	out.Preloads = append([]string(nil), q.Preloads...)
+	return &out
 }
 
-func (c *SelectStatement) Compile() (string, map[string]interface{}) {
-	outStatement := fmt.Sprintf("SELECT * FROM %s", c.Table)
+func (c *SelectStatement) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return compileWith(ctx, c.CommonTables, func() (string, map[string]interface{}) {
+		return c.compileSelect(ctx)
+	})
+}
+
+func (c *SelectStatement) compileSelect(ctx *CompileContext) (string, map[string]interface{}) {
+	outStatement := "SELECT"
 	outObjects := make(map[string]interface{})
 
+	if c.DistinctClause != nil {
+		distinctStmt, distinctObj := c.DistinctClause.Compile(ctx)
+		outStatement = fmt.Sprintf("%s %s", outStatement, distinctStmt)
+		outObjects = mapUnion(outObjects, distinctObj)
+	}
+
+	if len(c.Projection) > 0 {
+		columnStmt, columnObj := JoinClausesOn(ctx, c.projection(), sqlComma)
+		outStatement = fmt.Sprintf("%s %s", outStatement, columnStmt)
+		outObjects = mapUnion(outObjects, columnObj)
+	} else {
+		outStatement += " *"
+	}
+
+	if c.Source != nil {
+		sourceStmt, sourceObj := c.Source.Compile(ctx)
+		outStatement = fmt.Sprintf("%s FROM (%s)", outStatement, sourceStmt)
+		outObjects = mapUnion(outObjects, sourceObj)
+	} else {
+		outStatement = fmt.Sprintf("%s FROM %s", outStatement, c.Table)
+	}
+	if c.TableAlias != "" {
+		outStatement = fmt.Sprintf("%s AS %s", outStatement, c.TableAlias)
+	}
+
+	for _, v := range c.Joins {
+		joinStmt, joinObj := v.Compile(ctx)
+		outStatement = fmt.Sprintf("%s %s", outStatement, joinStmt)
+		outObjects = mapUnion(outObjects, joinObj)
+	}
+
 	if c.WhereClause != nil {
-		whereStmt, whereObj := c.WhereClause.Compile()
+		whereStmt, whereObj := c.WhereClause.Compile(ctx)
 		outStatement = fmt.Sprintf("%s WHERE (%s)", outStatement, whereStmt)
 		outObjects = mapUnion(outObjects, whereObj)
 	}
 
-	if c.OrderClause != nil {
-		orderStmt, orderObj := c.OrderClause.Compile()
+	if len(c.GroupClauses) > 0 {
+		groupStmt, groupObj := JoinClausesOn(ctx, c.GroupClauses, sqlComma)
+		outStatement = fmt.Sprintf("%s GROUP BY %s", outStatement, groupStmt)
+		outObjects = mapUnion(outObjects, groupObj)
+	}
+
+	if c.HavingClause != nil {
+		havingStmt, havingObj := c.HavingClause.Compile(ctx)
+		outStatement = fmt.Sprintf("%s HAVING (%s)", outStatement, havingStmt)
+		outObjects = mapUnion(outObjects, havingObj)
+	}
+
+	orderStmt, orderObj := compileOrderLimit(ctx, c.OrderClauses, c.LimitClause, c.OffsetClause)
+	outStatement += orderStmt
+	outObjects = mapUnion(outObjects, orderObj)
+
+	if c.LockClause != nil {
+		lockStmt, lockObj := c.LockClause.Compile(ctx)
+		outStatement = fmt.Sprintf("%s %s", outStatement, lockStmt)
+		outObjects = mapUnion(outObjects, lockObj)
+	}
+
+	return outStatement, outObjects
+}
+
+// The name the selected table goes by in the rest of the statement.
+func (c *SelectStatement) reference() string {
+	if c.TableAlias != "" {
+		return c.TableAlias
+	}
+	return c.Table
+}
+
+// Once tables are joined, unqualified columns of the projection are taken
+// from the selected table so they can't be ambiguous.
+func (c *SelectStatement) projection() []Clause {
+	if len(c.Joins) == 0 {
+		return c.Projection
+	}
+
+	out := make([]Clause, len(c.Projection))
+	for i, v := range c.Projection {
+		out[i] = v
+		if column, ok := v.(Column); ok && column.Table == "" {
+			column.Table = c.reference()
+			out[i] = column
+		}
+	}
+	return out
+}
+
+// With names the results of query for use in this statement.
+func (q *SelectStatement) With(name string, query Clause) *SelectStatement {
+	q = q.Clone()
+	q.CommonTables = q.CommonTables.with(name, query, false)
+	return q
+}
+
+// WithRecursive names a query that may refer to its own results, typically
+// a base case combined with the recursive case through UNION ALL.
+func (q *SelectStatement) WithRecursive(name string, query Clause) *SelectStatement {
+	q = q.Clone()
+	q.CommonTables = q.CommonTables.with(name, query, true)
+	return q
+}
+
+// From selects from the derived table of a subquery, aliased as alias.
+func (q *SelectStatement) From(query Clause, alias string) *SelectStatement {
+	q = q.Clone()
+	q.Source = query
+	q.Table = ""
+	q.TableAlias = alias
+	return q
+}
+
+// As aliases the selected table.
+func (q *SelectStatement) As(alias string) *SelectStatement {
+	q = q.Clone()
+	q.TableAlias = alias
+	return q
+}
+
+func (q *SelectStatement) joinOn(kind string, table string, alias string, on Clause) *SelectStatement {
+	q = q.Clone()
+	q.Joins = append(q.Joins, JoinClause{
+		Type:  kind,
+		Table: table,
+		Alias: alias,
+		On:    on,
+	})
+	return q
+}
+
+// Join inner joins table, aliased unless alias is empty, on a clause such as
+// On("story.author", "a.id").
+func (q *SelectStatement) Join(table string, alias string, on Clause) *SelectStatement {
+	return q.joinOn(InnerJoin, table, alias, on)
+}
+
+func (q *SelectStatement) LeftJoin(table string, alias string, on Clause) *SelectStatement {
+	return q.joinOn(LeftJoin, table, alias, on)
+}
+
+func (q *SelectStatement) RightJoin(table string, alias string, on Clause) *SelectStatement {
+	return q.joinOn(RightJoin, table, alias, on)
+}
+
+func (q *SelectStatement) CrossJoin(table string, alias string) *SelectStatement {
+	return q.joinOn(CrossJoin, table, alias, nil)
+}
+
+// Distinct removes duplicate rows from the results.
+func (q *SelectStatement) Distinct() *SelectStatement {
+	q = q.Clone()
+	q.DistinctClause = DistinctClause{}
+	return q
+}
+
+// DistinctOn keeps the first row of each set of rows with equal columns, as
+// ordered by the query. SQLite doesn't support it.
+func (q *SelectStatement) DistinctOn(columns ...string) *SelectStatement {
+	q = q.Clone()
+	on := make([]Clause, len(columns))
+	for i, v := range columns {
+		on[i] = ParseColumn(v)
+	}
+	q.DistinctClause = DistinctClause{
+		On: on,
+	}
+	return q
+}
+
+// Columns replaces the projection with the named columns, which may be
+// qualified as "table.column".
+func (q *SelectStatement) Columns(names ...string) *SelectStatement {
+	columns := make([]Clause, len(names))
+	for i, v := range names {
+		columns[i] = ParseColumn(v)
+	}
+	return q.Select(columns...)
+}
+
+// Select replaces the projection with columns, such as Column, Expression or
+// an As alias of either.
+func (q *SelectStatement) Select(columns ...Clause) *SelectStatement {
+	q = q.Clone()
+	q.Projection = columns
+	return q
+}
+
+// AddSelect appends columns to the current projection.
+func (q *SelectStatement) AddSelect(columns ...Clause) *SelectStatement {
+	q = q.Clone()
+	q.Projection = append(q.Projection, columns...)
+	return q
+}
+
+func (q *SelectStatement) GroupBy(columns ...string) *SelectStatement {
+	q = q.Clone()
+	for _, v := range columns {
+		q.GroupClauses = append(q.GroupClauses, ParseColumn(v))
+	}
+	return q
+}
+
+// Having filters groups, ANDed with any earlier Having, e.g.
+// Having(Compare(Count("*"), OpGreater, 5)).
+func (q *SelectStatement) Having(having Clause) *SelectStatement {
+	q = q.Clone()
+	q.HavingClause = andClause(q.HavingClause, having)
+	return q
+}
+
+// compileOrderLimit compiles the ORDER BY, LIMIT and OFFSET that end a query.
+func compileOrderLimit(ctx *CompileContext, orderClauses []OrderClause, limitClause Clause, offsetClause Clause) (string, map[string]interface{}) {
+	outStatement := ""
+	outObjects := make(map[string]interface{})
+
+	if len(orderClauses) > 0 {
+		orders := make([]Clause, len(orderClauses))
+		for i, v := range orderClauses {
+			orders[i] = v
+		}
+		orderStmt, orderObj := JoinClausesOn(ctx, orders, sqlComma)
 		outStatement = fmt.Sprintf("%s ORDER BY %s", outStatement, orderStmt)
 		outObjects = mapUnion(outObjects, orderObj)
 	}
 
-	if c.LimitClause != nil {
-		limitStmt, limitObj := c.LimitClause.Compile()
+	if limitClause == nil && offsetClause != nil {
+		limitClause = unlimited(ctx)
+	}
+
+	if limitClause != nil {
+		limitStmt, limitObj := limitClause.Compile(ctx)
 		outStatement = fmt.Sprintf("%s LIMIT %s", outStatement, limitStmt)
 		outObjects = mapUnion(outObjects, limitObj)
 	}
 
+	if offsetClause != nil {
+		offsetStmt, offsetObj := offsetClause.Compile(ctx)
+		outStatement = fmt.Sprintf("%s OFFSET %s", outStatement, offsetStmt)
+		outObjects = mapUnion(outObjects, offsetObj)
+	}
+
 	return outStatement, outObjects
 }
 
+func (q *SelectStatement) lock(strength string, wait string) *SelectStatement {
+	q = q.Clone()
+	q.LockClause = &LockClause{
+		Strength: strength,
+		Wait:     wait,
+	}
+	return q
+}
+
+func (q *SelectStatement) lockStrength() string {
+	if q.LockClause == nil {
+		return LockUpdate
+	}
+	return q.LockClause.Strength
+}
+
+func (q *SelectStatement) lockWait() string {
+	if q.LockClause == nil {
+		return LockWait
+	}
+	return q.LockClause.Wait
+}
+
+// ForUpdate locks the selected rows against changes by other transactions
+// until this one ends. Executing a locking query fails on SQLite.
+func (q *SelectStatement) ForUpdate() *SelectStatement {
+	return q.lock(LockUpdate, q.lockWait())
+}
+
+// ForShare locks the selected rows against changes, while still letting
+// other transactions share the lock.
+func (q *SelectStatement) ForShare() *SelectStatement {
+	return q.lock(LockShare, q.lockWait())
+}
+
+// NoWait fails instead of waiting for rows locked by others, locking FOR
+// UPDATE unless told otherwise.
+func (q *SelectStatement) NoWait() *SelectStatement {
+	return q.lock(q.lockStrength(), LockNoWait)
+}
+
+// SkipLocked leaves out rows locked by others, locking FOR UPDATE unless
+// told otherwise.
+func (q *SelectStatement) SkipLocked() *SelectStatement {
+	return q.lock(q.lockStrength(), LockSkipLocked)
+}
+
+// Some dialects only accept OFFSET after a LIMIT.
+func unlimited(ctx *CompileContext) Clause {
+	switch ctx.Driver {
+	case "sqlite3":
+		return Expression("-1")
+	}
+	return nil
+}
+
+// Order adds key to the ordering, after any keys already ordered on.
 func (q *SelectStatement) Order(key string, ascending bool) *SelectStatement {
-	q.OrderClause = &OrderClause{
+	return q.OrderNulls(key, ascending, NullsDefault)
+}
+
+func (q *SelectStatement) OrderNulls(key string, ascending bool, nulls NullsOrder) *SelectStatement {
+	q = q.Clone()
+	q.OrderClauses = append(q.OrderClauses, OrderClause{
 		Key:       key,
 		Ascending: ascending,
-	}
+		Nulls:     nulls,
+	})
 	return q
 }
 
 func (q *SelectStatement) Limit(number int) *SelectStatement {
+	q = q.Clone()
 	q.LimitClause = &LimitClause{
 		Number: number,
 	}
 	return q
 }
 
-func (q *SelectStatement) WhereClauseAnd(where Clause) *SelectStatement {
-	if q.WhereClause == nil {
-		a := make(AndClauses, 1)
-		a[0] = where
-		q.WhereClause = a
-	} else {
-		obj, ok := q.WhereClause.(AndClauses)
-		if !ok {
-			panic("Cannot and to something that isn't an AND Clause.")
-		}
-		obj = append(obj, where)
-		q.WhereClause = obj
+func (q *SelectStatement) Offset(number int) *SelectStatement {
+	q = q.Clone()
+	q.OffsetClause = &OffsetClause{
+		Number: number,
 	}
 	return q
 }
 
+// WhereClauseAnd requires where in addition to everything before it.
+func (q *SelectStatement) WhereClauseAnd(where Clause) *SelectStatement {
+	q = q.Clone()
+	q.WhereClause = andClause(q.WhereClause, where)
+	return q
+}
+
+// WhereClauseOr accepts where as an alternative to everything before it.
+func (q *SelectStatement) WhereClauseOr(where Clause) *SelectStatement {
+	q = q.Clone()
+	q.WhereClause = orClause(q.WhereClause, where)
+	return q
+}
+
 func (q *SelectStatement) Where(key string, value interface{}) *SelectStatement {
 	return q.WhereClauseAnd(&NamedEquality{
 		Name:  key,
@@ -83,9 +403,150 @@ func (q *SelectStatement) Where(key string, value interface{}) *SelectStatement
 	})
 }
 
+func (q *SelectStatement) OrWhere(key string, value interface{}) *SelectStatement {
+	return q.WhereClauseOr(&NamedEquality{
+		Name:  key,
+		Value: value,
+	})
+}
+
+func (q *SelectStatement) WhereExists(query Clause) *SelectStatement {
+	return q.WhereClauseAnd(Exists(query))
+}
+
+func (q *SelectStatement) WhereNotExists(query Clause) *SelectStatement {
+	return q.WhereClauseAnd(NotExists(query))
+}
+
+func (q *SelectStatement) WhereRaw(sql string, params map[string]interface{}) *SelectStatement {
+	return q.WhereClauseAnd(parenClause{Raw(sql, params)})
+}
+
+func (q *SelectStatement) WhereNot(where Clause) *SelectStatement {
+	return q.WhereClauseAnd(Not(where))
+}
+
+// WhereGroup ANDs the clauses built by fn, parenthesized, onto the query.
+func (q *SelectStatement) WhereGroup(fn func(*Group)) *SelectStatement {
+	g := &Group{}
+	fn(g)
+	if g.Clause == nil {
+		return q
+	}
+	return q.WhereClauseAnd(g)
+}
+
+// OrWhereGroup ORs the clauses built by fn, parenthesized, onto the query.
+func (q *SelectStatement) OrWhereGroup(fn func(*Group)) *SelectStatement {
+	g := &Group{}
+	fn(g)
+	if g.Clause == nil {
+		return q
+	}
+	return q.WhereClauseOr(g)
+}
+
+func (q *SelectStatement) WhereOp(key string, operator string, value interface{}) *SelectStatement {
+	return q.WhereClauseAnd(&NamedComparison{
+		Name:     key,
+		Operator: operator,
+		Value:    value,
+	})
+}
+
+func (q *SelectStatement) WhereNotEqual(key string, value interface{}) *SelectStatement {
+	return q.WhereOp(key, OpNotEqual, value)
+}
+
+func (q *SelectStatement) WhereGreater(key string, value interface{}) *SelectStatement {
+	return q.WhereOp(key, OpGreater, value)
+}
+
+func (q *SelectStatement) WhereGreaterEqual(key string, value interface{}) *SelectStatement {
+	return q.WhereOp(key, OpGreaterEqual, value)
+}
+
+func (q *SelectStatement) WhereLess(key string, value interface{}) *SelectStatement {
+	return q.WhereOp(key, OpLess, value)
+}
+
+func (q *SelectStatement) WhereLessEqual(key string, value interface{}) *SelectStatement {
+	return q.WhereOp(key, OpLessEqual, value)
+}
+
+func (q *SelectStatement) WhereLike(key string, pattern string) *SelectStatement {
+	return q.WhereOp(key, OpLike, pattern)
+}
+
+// ILIKE isn't understood by SQLite, which fails the statement.
+func (q *SelectStatement) WhereILike(key string, pattern string) *SelectStatement {
+	return q.WhereOp(key, OpILike, pattern)
+}
+
+func (q *SelectStatement) WhereBetween(key string, low interface{}, high interface{}) *SelectStatement {
+	return q.WhereClauseAnd(&NamedBetween{
+		Name: key,
+		Low:  low,
+		High: high,
+	})
+}
+
+func (q *SelectStatement) WhereNull(key string) *SelectStatement {
+	return q.WhereClauseAnd(&NamedNull{
+		Name: key,
+	})
+}
+
+func (q *SelectStatement) WhereNotNull(key string) *SelectStatement {
+	return q.WhereClauseAnd(&NamedNull{
+		Name: key,
+		Not:  true,
+	})
+}
+
+// WhereIn matches any element of values, which should be a slice or a
+// subquery.
+func (q *SelectStatement) WhereIn(key string, values interface{}) *SelectStatement {
+	return q.WhereClauseAnd(&In{
+		Name:   key,
+		Values: values,
+	})
+}
+
+func (q *SelectStatement) WhereNotIn(key string, values interface{}) *SelectStatement {
+	return q.WhereClauseAnd(&NotIn{
+		Name:   key,
+		Values: values,
+	})
+}
+
 func (q *SelectStatement) One(db Executor, object interface{}) error {
-	q.Limit(1)
-	stmt, obj := q.Compile()
+	err := queryOne(db, q.Limit(1), object)
+	if err != nil {
+		return err
+	}
+	return preloadAll(db, object, q.Preloads)
+}
+
+func (q *SelectStatement) All(db Executor, object interface{}) error {
+	err := queryAll(db, q, object)
+	if err != nil {
+		return err
+	}
+	return preloadAll(db, object, q.Preloads)
+}
+
+func (c *SelectStatement) Exec(db Executor) (sql.Result, error) {
+	return execute(db, c)
+}
+
+// queryOne scans the first row of query into object and loads its
+// relationships.
+func queryOne(db Executor, query Clause, object interface{}) error {
+	stmt, obj, err := compileFor(db, query)
+	if err != nil {
+		return err
+	}
 	rows, err := db.NamedQuery(stmt, obj)
 	if err != nil {
 		return err
@@ -102,27 +563,133 @@ func (q *SelectStatement) One(db Executor, object interface{}) error {
 	}
 
 	// Load Relationships
-	id := int64(-1)
-	examineObject(object, func(p PrimaryKey, name string) {
-		id = int64(p)
-	}, nil, nil, nil)
-	loadRelationships(object, id)
+	loadRelationships(object, nil)
 
 	return nil
 }
 
-func (q *SelectStatement) All(db Executor, object interface{}) error {
-	stmt, obj := q.Compile()
+// queryAll scans every row of query into object, a pointer to a slice, and
+// loads the relationships of each element.
+func queryAll(db Executor, query Clause, object interface{}) error {
+	stmt, obj, err := compileFor(db, query)
+	if err != nil {
+		return err
+	}
 	rows, err := db.NamedQuery(stmt, obj)
 	if err != nil {
 		return err
 	}
 	defer rows.Rows.Close()
 
-	return sqlx.StructScan(rows, object)
+	err = sqlx.StructScan(rows, object)
+	if err != nil {
+		return err
+	}
+
+	loadAllRelationships(object)
+	return nil
+}
+
+// Maps scans every row into a map of column name to value, for results that
+// have no matching struct such as aggregates.
+func (q *SelectStatement) Maps(db Executor) ([]map[string]interface{}, error) {
+	stmt, obj, err := compileFor(db, q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.NamedQuery(stmt, obj)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Rows.Close()
+
+	out := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		row := make(map[string]interface{})
+		err = rows.MapScan(row)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
 }
 
-func (c *SelectStatement) Exec(db Executor) (sql.Result, error) {
-	stmt, obj := c.Compile()
-	return db.NamedExec(stmt, obj)
+// Count returns the number of rows the query matches, ignoring its ordering.
+// Distinct, grouped and limited queries are counted as a subquery.
+func (q *SelectStatement) Count(db Executor) (int64, error) {
+	counted := q.Clone()
+	counted.OrderClauses = nil
+	counted.LockClause = nil
+
+	query := counted
+	if q.DistinctClause != nil || len(q.GroupClauses) > 0 || q.LimitClause != nil || q.OffsetClause != nil {
+		query = (&SelectStatement{}).From(counted, "counted")
+	}
+	query.Projection = []Clause{Count("*")}
+
+	stmt, obj, err := compileFor(db, query)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = queryScalar(db, stmt, obj, &count)
+	return count, err
+}
+
+// Exists reports whether the query matches any row.
+func (q *SelectStatement) Exists(db Executor) (bool, error) {
+	found := q.Limit(1)
+	found.Projection = []Clause{Expression("1")}
+	found.OrderClauses = nil
+	found.LockClause = nil
+
+	innerStmt, obj, err := compileFor(db, found)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = queryScalar(db, fmt.Sprintf("SELECT EXISTS (%s)", innerStmt), obj, &exists)
+	return exists, err
+}
+
+// Pluck scans a single column of every row into object, a pointer to a slice.
+func (q *SelectStatement) Pluck(db Executor, column string, object interface{}) error {
+	stmt, obj, err := compileFor(db, q.Columns(column))
+	if err != nil {
+		return err
+	}
+	rows, err := db.NamedQuery(stmt, obj)
+	if err != nil {
+		return err
+	}
+	defer rows.Rows.Close()
+
+	results := reflect.ValueOf(object).Elem()
+	for rows.Next() {
+		value := reflect.New(results.Type().Elem())
+		err = rows.Scan(value.Interface())
+		if err != nil {
+			return err
+		}
+		results.Set(reflect.Append(results, value.Elem()))
+	}
+	return rows.Err()
+}
+
+func queryScalar(db Executor, stmt string, obj map[string]interface{}, dest interface{}) error {
+	rows, err := db.NamedQuery(stmt, obj)
+	if err != nil {
+		return err
+	}
+	defer rows.Rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	return rows.Scan(dest)
 }
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// A Raw Query runs hand written SQL against DB, scanning its rows into
+// models just like a SelectStatement.
+type RawQuery struct {
+	DB     Executor
+	Clause Clause
+}
+
+// Query prepares stmt, with :name parameters bound from params, to run
+// against db.
+func Query(db Executor, stmt string, params map[string]interface{}) *RawQuery {
+	return &RawQuery{
+		DB:     db,
+		Clause: Raw(stmt, params),
+	}
+}
+
+// One scans the first row into object and loads its relationships.
+func (q *RawQuery) One(object interface{}) error {
+	return queryOne(q.DB, q.Clause, object)
+}
+
+// All scans every row into object, a pointer to a slice, and loads the
+// relationships of each element.
+func (q *RawQuery) All(object interface{}) error {
+	return queryAll(q.DB, q.Clause, object)
+}
+
+func (q *RawQuery) Exec() (sql.Result, error) {
+	return execute(q.DB, q.Clause)
+}
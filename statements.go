@@ -3,9 +3,10 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
-type insertHandler func(int64)
+type insertHandler func(sql.Result)
 type statementHandler func()
 
 type Statement interface {
@@ -18,50 +19,61 @@ type InsertStatement struct {
 	postExec insertHandler
 }
 
-func (c *InsertStatement) Compile() (string, map[string]interface{}) {
+func (c *InsertStatement) Compile(ctx *CompileContext) (string, map[string]interface{}) {
 	columns := ""
 	values := ""
-	for key, _ := range c.Values {
+	objects := make(map[string]interface{})
+	for key, value := range c.Values {
 		if columns != "" {
 			columns += ", "
 			values += ", "
 		}
+		name := ctx.Name(key)
 		columns += `"` + key + `"`
-		values += (":" + key)
+		values += (":" + name)
+		objects[name] = value
 	}
-	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", c.Table, columns, values), c.Values
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", c.Table, columns, values), objects
 }
 
 func (c *InsertStatement) Exec(db Executor) (sql.Result, error) {
-	stmt, obj := c.Compile()
-	results, err := db.NamedExec(stmt, obj)
+	results, err := execute(db, c)
 	if err == nil {
-		id, err := results.LastInsertId()
-		if err == nil {
-			c.postExec(id)
-		}
+		c.postExec(results)
 	}
 	return results, err
 }
 
 // Update Statement Creates an SQL Update
 type UpdateStatement struct {
-	Table    string
-	Where    Clause
-	Columns  Clause
-	postExec statementHandler
+	CommonTables WithClause
+	Table        string
+	Where        Clause
+	Columns      Clause
+	postExec     statementHandler
+}
+
+func (c *UpdateStatement) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return compileWith(ctx, c.CommonTables, func() (string, map[string]interface{}) {
+		where, whereObjects := c.Where.Compile(ctx)
+		set, setObjects := c.Columns.Compile(ctx)
+
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s", c.Table, set, where), mapUnion(whereObjects, setObjects)
+	})
 }
 
-func (c *UpdateStatement) Compile() (string, map[string]interface{}) {
-	where, whereObjects := c.Where.Compile()
-	set, setObjects := c.Columns.Compile()
+func (c *UpdateStatement) With(name string, query Clause) *UpdateStatement {
+	c.CommonTables = c.CommonTables.with(name, query, false)
+	return c
+}
 
-	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", c.Table, set, where), mapUnion(whereObjects, setObjects)
+func (c *UpdateStatement) WithRecursive(name string, query Clause) *UpdateStatement {
+	c.CommonTables = c.CommonTables.with(name, query, true)
+	return c
 }
 
 func (c *UpdateStatement) Exec(db Executor) (sql.Result, error) {
-	stmt, obj := c.Compile()
-	results, err := db.NamedExec(stmt, obj)
+	results, err := execute(db, c)
 	if err == nil {
 		c.postExec()
 	}
@@ -69,28 +81,82 @@ func (c *UpdateStatement) Exec(db Executor) (sql.Result, error) {
 }
 
 type DeleteStatement struct {
-	Table string
-	Where Clause
+	CommonTables WithClause
+	Table        string
+	Where        Clause
 }
 
-func (c *DeleteStatement) Compile() (string, map[string]interface{}) {
-	whereStmt, whereObj := c.Where.Compile()
-	return fmt.Sprintf("DELETE FROM %s WHERE %s", c.Table, whereStmt), whereObj
+func (c *DeleteStatement) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	return compileWith(ctx, c.CommonTables, func() (string, map[string]interface{}) {
+		whereStmt, whereObj := c.Where.Compile(ctx)
+		return fmt.Sprintf("DELETE FROM %s WHERE %s", c.Table, whereStmt), whereObj
+	})
+}
+
+func (c *DeleteStatement) With(name string, query Clause) *DeleteStatement {
+	c.CommonTables = c.CommonTables.with(name, query, false)
+	return c
+}
+
+func (c *DeleteStatement) WithRecursive(name string, query Clause) *DeleteStatement {
+	c.CommonTables = c.CommonTables.with(name, query, true)
+	return c
 }
 
 func (c *DeleteStatement) Exec(db Executor) (sql.Result, error) {
-	stmt, obj := c.Compile()
-	return db.NamedExec(stmt, obj)
+	return execute(db, c)
+}
+
+// Referential Actions
+const (
+	ActionNoAction   = "NO ACTION"
+	ActionRestrict   = "RESTRICT"
+	ActionCascade    = "CASCADE"
+	ActionSetNull    = "SET NULL"
+	ActionSetDefault = "SET DEFAULT"
+)
+
+// ParseAction reads a referential action as written in a struct tag, such as
+// "cascade" or "set_null". The empty string is the database's default.
+func ParseAction(action string) (string, error) {
+	action = strings.ToUpper(strings.Replace(action, "_", " ", -1))
+	switch action {
+	case "", ActionNoAction, ActionRestrict, ActionCascade, ActionSetNull, ActionSetDefault:
+		return action, nil
+	}
+	return "", fmt.Errorf("Unknown referential action %q.", action)
+}
+
+// A Foreign Key Constraint requires Columns to match References in Table,
+// applying OnDelete and OnUpdate when the referenced row changes.
+type ForeignKeyConstraint struct {
+	Columns    []string
+	Table      string
+	References []string
+	OnDelete   string
+	OnUpdate   string
+}
+
+func (c ForeignKeyConstraint) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	out := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)", strings.Join(c.Columns, sqlComma), c.Table, strings.Join(c.References, sqlComma))
+	if c.OnDelete != "" {
+		out += " ON DELETE " + c.OnDelete
+	}
+	if c.OnUpdate != "" {
+		out += " ON UPDATE " + c.OnUpdate
+	}
+	return out, nil
 }
 
 type CreateTableStatement struct {
-	Name   string
-	Fields []Field
-	Force  bool
-	Key    string
+	Name        string
+	Fields      []Field
+	Force       bool
+	Keys        []string
+	ForeignKeys []ForeignKeyConstraint
 }
 
-func (c *CreateTableStatement) Compile() (string, map[string]interface{}) {
+func (c *CreateTableStatement) Compile(ctx *CompileContext) (string, map[string]interface{}) {
 	exists := ""
 	if !c.Force {
 		exists = "IF NOT EXISTS"
@@ -104,14 +170,18 @@ func (c *CreateTableStatement) Compile() (string, map[string]interface{}) {
 		columns += fmt.Sprintf("\"%s\" %s", v.Name, v.Type)
 	}
 
-	if c.Key != "" {
-		columns += fmt.Sprintf(", CONSTRAINT %s_pk PRIMARY KEY (%s)", c.Name, c.Key)
+	if len(c.Keys) > 0 {
+		columns += fmt.Sprintf(", CONSTRAINT %s_pk PRIMARY KEY (%s)", c.Name, strings.Join(c.Keys, sqlComma))
+	}
+
+	for _, v := range c.ForeignKeys {
+		constraint, _ := v.Compile(ctx)
+		columns += sqlComma + constraint
 	}
 
 	return fmt.Sprintf("CREATE TABLE %s %s (%s)", exists, c.Name, columns), nil
 }
 
 func (c *CreateTableStatement) Exec(db Executor) (sql.Result, error) {
-	stmt, obj := c.Compile()
-	return db.NamedExec(stmt, obj)
+	return execute(db, c)
 }
@@ -1,7 +1,10 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
 	"reflect"
+	"sync"
 )
 
 type Database interface {
@@ -9,12 +12,14 @@ type Database interface {
 	DriverName() string
 }
 
-type handleprimaryKeyType func(PrimaryKey, string)
+type handleprimaryKeyType func(interface{}, string)
 type handlehasOneType func(*HasOne, string)
 type handlehasManyType func(*HasMany, string)
+type handlemanyToManyType func(*ManyToMany, string)
+type handlepolymorphicType func(*Polymorphic, string)
 type handleDefaultType func(interface{}, reflect.Kind, string)
 
-func examineObject(object interface{}, pt handleprimaryKeyType, ho handlehasOneType, hm handlehasManyType, d handleDefaultType) {
+func examineObject(object interface{}, pt handleprimaryKeyType, ho handlehasOneType, hm handlehasManyType, mm handlemanyToManyType, pm handlepolymorphicType, d handleDefaultType) {
 	// Value of Object
 	val := reflect.ValueOf(object).Elem()
 
@@ -24,9 +29,9 @@ func examineObject(object interface{}, pt handleprimaryKeyType, ho handlehasOneT
 		typeField := val.Type().Field(i)
 
 		switch typeField.Type {
-		case primaryKeyType:
+		case primaryKeyType, stringKeyType, uuidKeyType:
 			if pt != nil {
-				pt(valueField.Interface().(PrimaryKey), typeField.Name)
+				pt(keyValue(valueField.Interface()), typeField.Name)
 			}
 		case hasOneType:
 			if ho != nil {
@@ -36,6 +41,14 @@ func examineObject(object interface{}, pt handleprimaryKeyType, ho handlehasOneT
 			if hm != nil {
 				hm(valueField.Interface().(*HasMany), typeField.Name)
 			}
+		case manyToManyType:
+			if mm != nil {
+				mm(valueField.Interface().(*ManyToMany), typeField.Name)
+			}
+		case polymorphicType:
+			if pm != nil {
+				pm(valueField.Interface().(*Polymorphic), typeField.Name)
+			}
 		default:
 			if d != nil && typeField.Tag.Get("db") != "-" {
 				d(valueField.Interface(), valueField.Kind(), typeField.Name)
@@ -44,22 +57,67 @@ func examineObject(object interface{}, pt handleprimaryKeyType, ho handlehasOneT
 	}
 }
 
-// Author   *db.HasOne  `table:"author"`
-// StorySet *db.HasMany `table:"story", on:"author"`
+// columnValue finds the value stored for column in a struct (or pointer to
+// one), unwrapping primary and foreign keys.
+func columnValue(object reflect.Value, column string) (interface{}, bool) {
+	for object.Kind() == reflect.Ptr || object.Kind() == reflect.Interface {
+		object = object.Elem()
+	}
+
+	for i := 0; i < object.NumField(); i++ {
+		typeField := object.Type().Field(i)
+		name := typeField.Tag.Get("db")
+		if name == "" {
+			name = toSnakeCase(typeField.Name)
+		}
 
-func loadRelationships(object interface{}, id int64) {
+		if p, ok := object.Field(i).Interface().(*Polymorphic); ok && p != nil {
+			switch column {
+			case name + "_type":
+				return p.Type, true
+			case name + "_id":
+				return p.Value, true
+			}
+		}
+		if name != column {
+			continue
+		}
+
+		switch value := object.Field(i).Interface().(type) {
+		case PrimaryKey, StringKey:
+			return keyValue(value), true
+		case UUID:
+			return value.String(), true
+		case UUIDKey:
+			return value.String(), true
+		case *HasOne:
+			if value == nil {
+				return nil, true
+			}
+			return value.Value, true
+		default:
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Author   *db.HasOne      `table:"author"`
+// StorySet *db.HasMany     `table:"story", on:"author"`
+// Tags     *db.ManyToMany `table:"tag", through:"story_tag"`
+// Comments *db.HasMany     `table:"comment", as:"parent"`
+
+// loadRelationships sets up the relationship fields of object, after setting
+// its PrimaryKey to id if one is given.
+func loadRelationships(object interface{}, id interface{}) {
 	if reflect.TypeOf(object).Kind() != reflect.Ptr {
 		panic("Can't load relationships on non-pointer object.")
 	}
 	// Value of Object
 	val := reflect.ValueOf(object).Elem()
 
-	if id == -1 {
-		for i := 0; i < val.NumField(); i++ {
-			if val.Type().Field(i).Type == primaryKeyType {
-				id = int64(val.Field(i).Interface().(PrimaryKey))
-			}
-		}
+	if id == nil {
+		id = keyOf(object)
 	}
 
 	// Loop Through Fields
@@ -73,7 +131,9 @@ func loadRelationships(object interface{}, id int64) {
 
 		switch typeField.Type {
 		case primaryKeyType:
-			valueField.SetInt(id)
+			if key, ok := id.(int64); ok {
+				valueField.SetInt(key)
+			}
 		case hasOneType:
 			// Current Field Value
 			current := valueField.Interface().(*HasOne)
@@ -82,7 +142,7 @@ func loadRelationships(object interface{}, id int64) {
 				foreignColumn = "id"
 			}
 
-			value := 0
+			var value interface{}
 			if current != nil {
 				value = current.Value
 			}
@@ -92,23 +152,46 @@ func loadRelationships(object interface{}, id int64) {
 				column: foreignColumn,
 			}
 
-			hasOne.SelectStatement = (&SelectStatement{
-				Table: toSnakeCase(foreignTable),
-			}).Where(toSnakeCase(foreignColumn), value)
+			hasOne.SelectStatement = selectFrom(toSnakeCase(foreignTable)).Where(toSnakeCase(foreignColumn), value)
 			// Set New Value
 			valueField.Set(reflect.ValueOf(hasOne))
 		case hasManyType:
 			// Load Into New Value
 			hasMany := &HasMany{}
-			hasMany.SelectStatement = (&SelectStatement{
-				Table: toSnakeCase(foreignTable),
-			}).Where(toSnakeCase(foreignColumn), id)
+			hasMany.SelectStatement = selectFrom(toSnakeCase(foreignTable))
+			if as := typeField.Tag.Get("as"); as != "" {
+				// Rows pointing back through a Polymorphic field
+				hasMany.SelectStatement = hasMany.Where(toSnakeCase(as)+"_type", tableOf(val.Type())).Where(toSnakeCase(as)+"_id", id)
+			} else {
+				hasMany.SelectStatement = hasMany.Where(toSnakeCase(foreignColumn), id)
+			}
 			// Set New Value
 			valueField.Set(reflect.ValueOf(hasMany))
+		case manyToManyType:
+			valueField.Set(reflect.ValueOf(newManyToMany(val.Type(), typeField, id)))
 		}
 	}
 }
 
+// loadAllRelationships loads the relationships of every struct in object, a
+// pointer to a slice of structs or of pointers to structs.
+func loadAllRelationships(object interface{}) {
+	results := reflect.ValueOf(object).Elem()
+	for i := 0; i < results.Len(); i++ {
+		element := results.Index(i)
+		if element.Kind() == reflect.Ptr {
+			if element.IsNil() {
+				continue
+			}
+			element = element.Elem()
+		}
+		if element.Kind() != reflect.Struct {
+			return
+		}
+		loadRelationships(element.Addr().Interface(), nil)
+	}
+}
+
 func scan(object interface{}) {
 	if reflect.TypeOf(object).Kind() != reflect.Ptr {
 		panic("Can't scan into object that isn't a pointer.")
@@ -129,19 +212,23 @@ type Table interface {
 }
 
 type BasicTable struct {
-	TableName string
-	Fieldset  []Field
-	Key       string
-	DB        Executor
+	TableName   string
+	Fieldset    []Field
+	Keys        []string
+	ForeignKeys []ForeignKeyConstraint
+	DB          Executor
 }
 
 func ConvertKindToDB(db Database, r reflect.Kind, pk bool) string {
 	if pk && db.DriverName() == "postgres" {
-		return "serial"
+		return "bigserial"
 	}
 
 	switch r {
-	case reflect.Int:
+	case reflect.Int, reflect.Int64:
+		if db.DriverName() == "postgres" {
+			return "bigint"
+		}
 		return "integer"
 	case reflect.String:
 		return "text"
@@ -158,6 +245,191 @@ func ConvertKindToDB(db Database, r reflect.Kind, pk bool) string {
 	return "unknown"
 }
 
+var registry = struct {
+	sync.RWMutex
+	models map[string]reflect.Type
+}{
+	models: make(map[string]reflect.Type),
+}
+
+// Register records the struct type of object as the model stored in table
+// name, so that relationships pointing at the table can be preloaded.
+// CreateTableFromStruct registers the tables it creates.
+func Register(name string, object interface{}) {
+	model := reflect.TypeOf(object)
+	for model.Kind() == reflect.Ptr {
+		model = model.Elem()
+	}
+	if model.Kind() != reflect.Struct {
+		panic("Can't register a model that isn't a struct.")
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	registry.models[name] = model
+}
+
+func registeredModel(name string) (reflect.Type, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	model, ok := registry.models[name]
+	return model, ok
+}
+
+// registeredName finds the table registered for the model of object, a
+// struct type or pointer to one.
+func registeredName(model reflect.Type) (string, bool) {
+	for model.Kind() == reflect.Ptr {
+		model = model.Elem()
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+	for k, v := range registry.models {
+		if v == model {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// tableOf names the table of model, falling back on its snake cased name
+// when it isn't registered.
+func tableOf(model reflect.Type) string {
+	if name, ok := registeredName(model); ok {
+		return name
+	}
+	return toSnakeCase(model.Name())
+}
+
+// selectFrom selects the rows of table. Registered models have their mapped
+// columns listed rather than *, so that columns added to the table don't
+// break scanning, with Polymorphic pairs aliased into the nested struct.
+func selectFrom(table string) *SelectStatement {
+	q := &SelectStatement{
+		Table: table,
+	}
+	if model, ok := registeredModel(table); ok {
+		q.Projection = modelColumns(model, "")
+	}
+	return q
+}
+
+// polymorphicColumns maps the columns of the Polymorphic fields of model to
+// their projections, qualified by table if given and aliased to the nested
+// names they scan into.
+func polymorphicColumns(model reflect.Type, table string) map[string]Clause {
+	out := make(map[string]Clause)
+	for i := 0; i < model.NumField(); i++ {
+		if model.Field(i).Type == polymorphicType {
+			name := toSnakeCase(model.Field(i).Name)
+			out[name+"_type"] = As(emptyIfNull{Column{Table: table, Name: name + "_type"}}, name+".type")
+			out[name+"_id"] = As(Column{Table: table, Name: name + "_id"}, name+".id")
+		}
+	}
+	return out
+}
+
+// modelColumns projects the columns of model, qualified by table if given.
+func modelColumns(model reflect.Type, table string) []Clause {
+	column := func(name string) Clause {
+		return Column{
+			Table: table,
+			Name:  name,
+		}
+	}
+
+	polymorphic := polymorphicColumns(model, table)
+	out := make([]Clause, 0, model.NumField())
+	examineObject(reflect.New(model).Interface(),
+		func(k interface{}, name string) {
+			out = append(out, column(toSnakeCase(name)))
+		},
+		func(p *HasOne, name string) {
+			out = append(out, column(toSnakeCase(name)))
+		},
+		nil,
+		nil,
+		func(p *Polymorphic, name string) {
+			name = toSnakeCase(name)
+			out = append(out, polymorphic[name+"_type"], polymorphic[name+"_id"])
+		},
+		func(d interface{}, r reflect.Kind, name string) {
+			out = append(out, column(toSnakeCase(name)))
+		})
+	return out
+}
+
+// emptyIfNull reads NULL from a text column as the empty string, so that a
+// Polymorphic pointing nowhere scans into its Type.
+type emptyIfNull struct {
+	Clause
+}
+
+func (c emptyIfNull) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	stmt, obj := c.Clause.Compile(ctx)
+	return fmt.Sprintf("COALESCE(%s, '')", stmt), obj
+}
+
+// keyColumns lists the primary key columns of model: its PrimaryKey,
+// StringKey or UUIDKey field along with any fields tagged `primary:"true"`, in
+// field order.
+func keyColumns(model reflect.Type) []string {
+	out := make([]string, 0, 1)
+	for i := 0; i < model.NumField(); i++ {
+		field := model.Field(i)
+		switch {
+		case field.Type == primaryKeyType, field.Type == stringKeyType, field.Type == uuidKeyType, field.Tag.Get("primary") == "true":
+			out = append(out, toSnakeCase(field.Name))
+		}
+	}
+	return out
+}
+
+// keyClause matches the key columns keys to values.
+func keyClause(keys []string, values []interface{}) Clause {
+	if len(keys) == 1 {
+		return &NamedEquality{
+			Name:  keys[0],
+			Value: values[0],
+		}
+	}
+
+	out := make(AndClauses, len(keys))
+	for i, v := range keys {
+		out[i] = &NamedEquality{
+			Name:  v,
+			Value: values[i],
+		}
+	}
+	return out
+}
+
+// objectKeyClause matches the row of object by its key. Objects without a
+// key fail the statement instead, since no row can be picked out.
+func objectKeyClause(object interface{}) Clause {
+	keys := keyColumns(reflect.TypeOf(object).Elem())
+	if len(keys) == 0 {
+		return failedClause{fmt.Errorf("Can't identify the row of %T without a key.", object)}
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, v := range keys {
+		values[i], _ = columnValue(reflect.ValueOf(object), v)
+	}
+	return keyClause(keys, values)
+}
+
+// failedClause fails the statement it is compiled into with Err.
+type failedClause struct {
+	Err error
+}
+
+func (c failedClause) Compile(ctx *CompileContext) (string, map[string]interface{}) {
+	ctx.Fail(c.Err)
+	return "1 = 0", nil
+}
+
 func CreateTableFromStruct(name string, db Database, force bool, object interface{}) (*BasicTable, error) {
 	// Create Table Struct
 	out := &BasicTable{
@@ -166,46 +438,193 @@ func CreateTableFromStruct(name string, db Database, force bool, object interfac
 		DB:        db,
 	}
 
+	model := reflect.TypeOf(object).Elem()
+	out.Keys = keyColumns(model)
+
+	// Databases only assign a PrimaryKey that makes up the whole key.
+	if len(out.Keys) > 1 {
+		for i := 0; i < model.NumField(); i++ {
+			if model.Field(i).Type == primaryKeyType {
+				return out, fmt.Errorf("Table %s can't have a PrimaryKey in a composite key, use StringKey or UUIDKey.", name)
+			}
+		}
+	}
+
+	Register(name, object)
+
+	links := make([]*CreateTableStatement, 0)
+	var fieldErr error
+
 	// Fillout Fieldset
 	examineObject(object,
-		func(p PrimaryKey, name string) {
+		func(k interface{}, name string) {
 			out.Fieldset = append(out.Fieldset, Field{
 				Name: toSnakeCase(name),
-				Type: ConvertKindToDB(db, reflect.Int, true),
+				Type: keyColumnType(db, model, true),
 			})
-			out.Key = toSnakeCase(name)
 		},
 		func(p *HasOne, name string) {
+			// Foreign keys match the key of the related model.
+			field, _ := model.FieldByName(name)
+			related, ok := registeredModel(toSnakeCase(field.Tag.Get("table")))
+			if !ok {
+				fieldErr = unregisteredError(out.TableName, toSnakeCase(field.Tag.Get("table")))
+				return
+			}
 			out.Fieldset = append(out.Fieldset, Field{
 				Name: toSnakeCase(name),
-				Type: ConvertKindToDB(db, reflect.Int, false),
+				Type: keyColumnType(db, related, false),
+			})
+
+			// Foreign Key Constraint
+			column := field.Tag.Get("on")
+			if column == "" {
+				column = "id"
+			}
+			onDelete, err := ParseAction(field.Tag.Get("ondelete"))
+			if err != nil {
+				fieldErr = err
+			}
+			onUpdate, err := ParseAction(field.Tag.Get("onupdate"))
+			if err != nil {
+				fieldErr = err
+			}
+			out.ForeignKeys = append(out.ForeignKeys, ForeignKeyConstraint{
+				Columns:    []string{toSnakeCase(name)},
+				Table:      toSnakeCase(field.Tag.Get("table")),
+				References: []string{toSnakeCase(column)},
+				OnDelete:   onDelete,
+				OnUpdate:   onUpdate,
 			})
 		},
 		nil,
+		func(p *ManyToMany, name string) {
+			field, _ := model.FieldByName(name)
+			m := newManyToMany(model, field, nil)
+			related, ok := registeredModel(m.Table)
+			if !ok {
+				fieldErr = unregisteredError(out.TableName, m.Table)
+				return
+			}
+			column := field.Tag.Get("on")
+			if column == "" {
+				column = "id"
+			}
+
+			// Links go with the rows on either side.
+			link := &CreateTableStatement{
+				Name: m.through,
+				Fields: []Field{
+					{Name: m.key, Type: keyColumnType(db, model, false)},
+					{Name: m.foreign, Type: keyColumnType(db, related, false)},
+				},
+				Force: force,
+				Keys:  []string{m.key, m.foreign},
+				ForeignKeys: []ForeignKeyConstraint{{
+					Columns:    []string{m.foreign},
+					Table:      m.Table,
+					References: []string{toSnakeCase(column)},
+					OnDelete:   ActionCascade,
+				}},
+			}
+			if len(out.Keys) == 1 {
+				link.ForeignKeys = append([]ForeignKeyConstraint{{
+					Columns:    []string{m.key},
+					Table:      out.TableName,
+					References: out.Keys,
+					OnDelete:   ActionCascade,
+				}}, link.ForeignKeys...)
+			}
+			links = append(links, link)
+		},
+		func(p *Polymorphic, name string) {
+			out.Fieldset = append(out.Fieldset, Field{
+				Name: toSnakeCase(name) + "_type",
+				Type: ConvertKindToDB(db, reflect.String, false),
+			}, Field{
+				Name: toSnakeCase(name) + "_id",
+				Type: ConvertKindToDB(db, reflect.String, false),
+			})
+		},
 		func(p interface{}, r reflect.Kind, name string) {
+			columnType := ConvertKindToDB(db, r, false)
+			if _, ok := p.(UUID); ok {
+				columnType = uuidColumnType(db)
+			}
 			out.Fieldset = append(out.Fieldset, Field{
 				Name: toSnakeCase(name),
-				Type: ConvertKindToDB(db, r, false),
+				Type: columnType,
 			})
 		})
 
+	if fieldErr != nil {
+		return out, fieldErr
+	}
+
 	// Create Table
 	_, err := out.CreateTable(force).Exec(db)
-	return out, err
+	if err != nil {
+		return out, err
+	}
+
+	// Create Link Tables
+	for _, v := range links {
+		_, err = v.Exec(db)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// unregisteredError reports a relationship of table to a table that hasn't
+// been created or registered, whose key type is therefore unknown.
+func unregisteredError(table string, related string) error {
+	return fmt.Errorf("Table %s references %s, which must be created or registered first.", table, related)
+}
+
+// EnableForeignKeys turns on the foreign key enforcement that SQLite leaves
+// off by default; other databases always enforce them. The pragma only covers
+// the connection it runs on, so pooled connections should instead open SQLite
+// with "_foreign_keys=on" in the DSN.
+func EnableForeignKeys(db Database) error {
+	if db.DriverName() != "sqlite3" {
+		return nil
+	}
+	_, err := execute(db, Raw("PRAGMA foreign_keys = ON", nil))
+	return err
 }
 
 func (b BasicTable) CreateTable(force bool) *CreateTableStatement {
 	return &CreateTableStatement{
-		Name:   b.TableName,
-		Fields: b.Fieldset,
-		Force:  force,
-		Key:    b.Key,
+		Name:        b.TableName,
+		Fields:      b.Fieldset,
+		Force:       force,
+		Keys:        b.Keys,
+		ForeignKeys: b.ForeignKeys,
 	}
 }
 
+// Get selects the mapped columns of the table, so that columns unknown to the
+// struct never reach StructScan.
 func (b BasicTable) Get() *SelectStatement {
+	polymorphic := make(map[string]Clause)
+	if model, ok := registeredModel(b.TableName); ok {
+		polymorphic = polymorphicColumns(model, "")
+	}
+
+	columns := make([]Clause, len(b.Fieldset))
+	for i, v := range b.Fieldset {
+		columns[i] = Column{
+			Name: v.Name,
+		}
+		if projection, ok := polymorphic[v.Name]; ok {
+			columns[i] = projection
+		}
+	}
 	return &SelectStatement{
-		Table: b.TableName,
+		Table:      b.TableName,
+		Projection: columns,
 	}
 }
 
@@ -213,43 +632,72 @@ func (b BasicTable) GetBy(object interface{}, key string, value interface{}) err
 	return b.Get().Where(key, value).One(b.DB, object)
 }
 
-func (b BasicTable) Delete(object interface{}) *DeleteStatement {
-	id := 0
-	idField := ""
+// GetByKey loads the row whose key columns, in the order of the fields of
+// object, hold values.
+func (b BasicTable) GetByKey(object interface{}, values ...interface{}) error {
+	keys := keyColumns(reflect.TypeOf(object).Elem())
+	if len(keys) != len(values) {
+		return fmt.Errorf("Table %s has %d key columns, not %d.", b.TableName, len(keys), len(values))
+	}
 
-	examineObject(object, func(p PrimaryKey, n string) {
-		id = int(p)
-		idField = toSnakeCase(n)
-	}, nil, nil, nil)
+	query := b.Get()
+	for i, v := range keys {
+		query = query.Where(v, values[i])
+	}
+	return query.One(b.DB, object)
+}
 
+func (b BasicTable) Delete(object interface{}) *DeleteStatement {
 	return &DeleteStatement{
 		Table: b.TableName,
-		Where: &NamedEquality{
-			Name:  idField,
-			Value: id,
-		},
+		Where: objectKeyClause(object),
 	}
 }
 
 func (b BasicTable) Update(object interface{}) *UpdateStatement {
-	id := 0
-	idField := ""
-
 	columnsClause := make(SetClause, 0)
 
+	// Key columns identify the row rather than being set.
+	keys := make(map[string]bool)
+	for _, v := range keyColumns(reflect.TypeOf(object).Elem()) {
+		keys[v] = true
+	}
+
 	examineObject(object,
-		func(p PrimaryKey, n string) {
-			id = int(p)
-			idField = toSnakeCase(n)
-		},
+		nil,
 		func(ho *HasOne, name string) {
+			if keys[toSnakeCase(name)] {
+				return
+			}
+			var value interface{}
+			if ho != nil {
+				value = ho.Value
+			}
 			columnsClause = append(columnsClause, &NamedEquality{
 				Name:  toSnakeCase(name),
-				Value: ho.Value,
+				Value: value,
 			})
 		},
 		nil,
+		nil,
+		func(p *Polymorphic, name string) {
+			value := &Polymorphic{}
+			if p != nil {
+				value = p
+			}
+
+			columnsClause = append(columnsClause, &NamedEquality{
+				Name:  toSnakeCase(name) + "_type",
+				Value: value.Type,
+			}, &NamedEquality{
+				Name:  toSnakeCase(name) + "_id",
+				Value: value.Value,
+			})
+		},
 		func(d interface{}, r reflect.Kind, name string) {
+			if keys[toSnakeCase(name)] {
+				return
+			}
 			columnsClause = append(columnsClause, &NamedEquality{
 				Name:  toSnakeCase(name),
 				Value: d,
@@ -257,14 +705,11 @@ func (b BasicTable) Update(object interface{}) *UpdateStatement {
 		})
 
 	return &UpdateStatement{
-		Table: b.TableName,
-		Where: &NamedEquality{
-			Name:  idField,
-			Value: id,
-		},
+		Table:   b.TableName,
+		Where:   objectKeyClause(object),
 		Columns: columnsClause,
 		postExec: func() {
-			loadRelationships(object, -1)
+			loadRelationships(object, nil)
 		},
 	}
 }
@@ -275,7 +720,8 @@ func (b BasicTable) Insert(object interface{}) *InsertStatement {
 	examineObject(object,
 		nil,
 		func(ho *HasOne, name string) {
-			value := 0
+			// No relationship is NULL, which foreign keys allow.
+			var value interface{}
 			if ho != nil {
 				value = ho.Value
 			}
@@ -283,15 +729,44 @@ func (b BasicTable) Insert(object interface{}) *InsertStatement {
 			values[toSnakeCase(name)] = value
 		},
 		nil,
+		nil,
+		func(p *Polymorphic, name string) {
+			value := &Polymorphic{}
+			if p != nil {
+				value = p
+			}
+
+			values[toSnakeCase(name)+"_type"] = value.Type
+			values[toSnakeCase(name)+"_id"] = value.Value
+		},
 		func(d interface{}, r reflect.Kind, name string) {
 			values[toSnakeCase(name)] = d
 		})
 
+	// Keys the database doesn't assign are generated here.
+	generated := false
+	val := reflect.ValueOf(object).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		switch val.Type().Field(i).Type {
+		case stringKeyType, uuidKeyType:
+			values[toSnakeCase(val.Type().Field(i).Name)] = generateKey(val.Field(i))
+			generated = true
+		}
+	}
+
 	return &InsertStatement{
 		Table:  b.TableName,
 		Values: values,
-		postExec: func(id int64) {
-			loadRelationships(object, id)
+		postExec: func(results sql.Result) {
+			if generated {
+				loadRelationships(object, nil)
+				return
+			}
+
+			id, err := results.LastInsertId()
+			if err == nil {
+				loadRelationships(object, id)
+			}
 		},
 	}
 }